@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"docs4context-com/internal/discover"
+	"docs4context-com/internal/manifest"
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/store"
+)
+
+// runCommand runs the command line command named by args[0]
+func runCommand(args []string) error {
+	switch args[0] {
+	case "import":
+		return runImport(args[1:])
+	case "batch":
+		return runBatch(args[1:])
+	case "sync":
+		return runSync(args[1:])
+	case "discover":
+		return runDiscover(args[1:])
+	case "refresh":
+		return runRefresh(args[1:])
+	default:
+		return fmt.Errorf("unknown command %q; run with --help for usage", args[0])
+	}
+}
+
+// runImport imports local llms.txt files and directories into the store
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	repo := flags.String("repo", "", "Repository (owner/name) to store a single file under")
+	scope := flags.String("scope", store.DefaultScope, "Store to import into: 'project' or 'global'")
+	outputDir := flags.String("output-dir", "", "Directory to import into, overriding --scope")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: docs4context-com import [--repo owner/name] [--scope project|global] [--output-dir DIR] PATH")
+	}
+
+	dir, err := savecontext.ResolveOutputDir(*outputDir, *scope)
+	if err != nil {
+		return err
+	}
+	results, err := savecontext.Import(flags.Arg(0), *repo, dir)
+	for _, imported := range results {
+		fmt.Printf("Imported %s to %s (%d snippets, %d tokens)\n", imported.Repo, imported.Path, imported.Snippets, imported.TokenCount)
+	}
+	return err
+}
+
+// runBatch downloads the documents of many repositories concurrently
+func runBatch(args []string) error {
+	flags := flag.NewFlagSet("batch", flag.ContinueOnError)
+	manifest := flags.String("manifest", "", "Text file listing repositories, one per line")
+	concurrency := flags.Int("concurrency", savecontext.DefaultConcurrency, "Number of documents to download at a time")
+	scope := flags.String("scope", store.DefaultScope, "Store to save to: 'project' or 'global'")
+	outputDir := flags.String("output-dir", "", "Directory to save to, overriding --scope")
+	sourceName := flags.String("source", "", "Source to download repositories from")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	targets := flags.Args()
+	if *manifest != "" {
+		listed, err := savecontext.ReadManifest(*manifest)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, listed...)
+	}
+	if len(targets) == 0 {
+		return fmt.Errorf("usage: docs4context-com batch [--manifest FILE] [--concurrency N] [--scope project|global] [--output-dir DIR] [--source NAME] [REPO...]")
+	}
+	if *concurrency < 1 || *concurrency > savecontext.MaxConcurrency {
+		return fmt.Errorf("--concurrency must be between 1 and %d", savecontext.MaxConcurrency)
+	}
+
+	dir, err := savecontext.ResolveOutputDir(*outputDir, *scope)
+	if err != nil {
+		return err
+	}
+	results := savecontext.Batch(context.Background(), savecontext.Requests(targets, savecontext.Request{Source: *sourceName, OutputDir: dir}), *concurrency)
+	fmt.Println(savecontext.FormatBatch(results))
+	if _, failed := savecontext.CountBatch(results); failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed", failed, len(results))
+	}
+	return nil
+}
+
+// runSync brings the project store in line with the project manifest
+func runSync(args []string) error {
+	flags := flag.NewFlagSet("sync", flag.ContinueOnError)
+	path := flags.String("manifest", manifest.DefaultFile, "Project manifest listing the required documents")
+	dryRun := flags.Bool("dry-run", false, "Only show what would change")
+	concurrency := flags.Int("concurrency", savecontext.DefaultConcurrency, "Number of documents to download at a time")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: docs4context-com sync [--manifest FILE] [--dry-run] [--concurrency N]")
+	}
+	if *concurrency < 1 || *concurrency > savecontext.MaxConcurrency {
+		return fmt.Errorf("--concurrency must be between 1 and %d", savecontext.MaxConcurrency)
+	}
+
+	m, err := manifest.Load(*path)
+	if err != nil {
+		return err
+	}
+	changes, err := manifest.Sync(context.Background(), m, store.Root(), *dryRun, *concurrency)
+	if err != nil {
+		return err
+	}
+	fmt.Println(manifest.FormatChanges(*path, changes, *dryRun))
+	for _, change := range changes {
+		if change.Error != "" {
+			return fmt.Errorf("some documents could not be synced")
+		}
+	}
+	return nil
+}
+
+// runDiscover proposes, or downloads, the documents of the dependencies of a project
+func runDiscover(args []string) error {
+	flags := flag.NewFlagSet("discover", flag.ContinueOnError)
+	run := flags.Bool("run", false, "Download the documents instead of only proposing them")
+	concurrency := flags.Int("concurrency", savecontext.DefaultConcurrency, "Number of documents to download at a time")
+	scope := flags.String("scope", store.DefaultScope, "Store to save to: 'project' or 'global'")
+	outputDir := flags.String("output-dir", "", "Directory to save to, overriding --scope")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("usage: docs4context-com discover [--run] [--concurrency N] [--scope project|global] [--output-dir DIR] [DIR]")
+	}
+	if *concurrency < 1 || *concurrency > savecontext.MaxConcurrency {
+		return fmt.Errorf("--concurrency must be between 1 and %d", savecontext.MaxConcurrency)
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	ctx := context.Background()
+	if !*run {
+		result, err := discover.Discover(ctx, dir)
+		if err != nil {
+			return err
+		}
+		text, err := discover.FormatProposals(result)
+		if err != nil {
+			return err
+		}
+		fmt.Println(text)
+		return nil
+	}
+
+	saveDir, err := savecontext.ResolveOutputDir(*outputDir, *scope)
+	if err != nil {
+		return err
+	}
+	result, err := discover.Discover(ctx, dir)
+	if err != nil {
+		return err
+	}
+	saved := discover.Run(ctx, result, saveDir, *concurrency)
+	fmt.Println(discover.FormatRun(result, saved))
+	if _, failed := savecontext.CountBatch(saved); failed > 0 {
+		return fmt.Errorf("%d of %d repositories failed", failed, len(saved))
+	}
+	return nil
+}
+
+// runRefresh downloads stale documents again, rewriting the ones that changed
+func runRefresh(args []string) error {
+	flags := flag.NewFlagSet("refresh", flag.ContinueOnError)
+	maxAgeDays := flags.Int("max-age-days", savecontext.DefaultMaxAgeDays, "Refresh documents checked more than this many days ago")
+	dryRun := flags.Bool("dry-run", false, "Only show which documents are stale")
+	concurrency := flags.Int("concurrency", savecontext.DefaultConcurrency, "Number of documents to download at a time")
+	scope := flags.String("scope", store.DefaultScope, "Store to refresh: 'project' or 'global'")
+	outputDir := flags.String("output-dir", "", "Directory to refresh, overriding --scope")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *maxAgeDays < 0 {
+		return fmt.Errorf("--max-age-days must not be negative")
+	}
+	if *concurrency < 1 || *concurrency > savecontext.MaxConcurrency {
+		return fmt.Errorf("--concurrency must be between 1 and %d", savecontext.MaxConcurrency)
+	}
+
+	dir, err := savecontext.ResolveOutputDir(*outputDir, *scope)
+	if err != nil {
+		return err
+	}
+	results, err := savecontext.Refresh(context.Background(), dir, flags.Args(), *maxAgeDays, *dryRun, *concurrency)
+	if err != nil {
+		return err
+	}
+	fmt.Println(savecontext.FormatRefresh(dir, results, *dryRun))
+	for _, result := range results {
+		if result.Status == savecontext.RefreshFailed {
+			return fmt.Errorf("some documents could not be refreshed")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/snapshot"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Latest names the version of a document downloaded fresh from its source
+const Latest = "latest"
+
+// Fields of a topic that a modification can change
+const (
+	FieldDescription = "description"
+	FieldSource      = "source"
+	FieldCode        = "code"
+)
+
+// Topic is a snippet that was added or removed
+type Topic struct {
+	Title  string `json:"title"`
+	Source string `json:"source,omitempty"`
+	// Line is the line of the title in the stored document, 0 for a download
+	Line int `json:"line,omitempty"`
+}
+
+// Modification is a snippet found in both versions with different content
+type Modification struct {
+	Title string `json:"title"`
+	// Source is the source in the new version
+	Source string `json:"source,omitempty"`
+	// Changes lists the fields that differ: description, source and code
+	Changes  []string `json:"changes"`
+	FromLine int      `json:"from_line,omitempty"`
+	ToLine   int      `json:"to_line,omitempty"`
+}
+
+// Result is the snippet-level difference between two versions of a document
+type Result struct {
+	Repo      string         `json:"repo"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Added     []Topic        `json:"added"`
+	Removed   []Topic        `json:"removed"`
+	Modified  []Modification `json:"modified"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// AddDiffTool adds the tool comparing two versions of a document
+func AddDiffTool(s *server.MCPServer) {
+	diffTool := mcp.NewTool("diff_context",
+		mcp.WithDescription("Compare two versions of a repository's context document topic by topic, reporting the topics added, removed and modified, matched by title and source. Versions are snapshots, the current document or a fresh download from its source."),
+		mcp.WithString("repo",
+			mcp.Required(),
+			mcp.Description("Repository to compare, as 'owner/repo' or 'owner/repo@variant'"),
+		),
+		mcp.WithString("from",
+			mcp.Description(fmt.Sprintf("Older version: a snapshot ID as listed by list_context_snapshots, or '%s' (default)", snapshot.Current)),
+		),
+		mcp.WithString("to",
+			mcp.Description(fmt.Sprintf("Newer version: a snapshot ID, '%s', or '%s' (default) to download the document again from its source without saving it", snapshot.Current, Latest)),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(diffTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("DIFF_CONTEXT tool called")
+
+		repo, err := request.RequireString("repo")
+		if err != nil {
+			log.Printf("DIFF_CONTEXT tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		repoID, err := store.ParseRepo(repo)
+		if err != nil {
+			log.Printf("DIFF_CONTEXT tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("DIFF_CONTEXT tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		from := request.GetString("from", snapshot.Current)
+		to := request.GetString("to", Latest)
+		if from == Latest {
+			log.Printf("DIFF_CONTEXT tool error - invalid parameter 'from': %s", from)
+			return mcp.NewToolResultError(fmt.Sprintf("'from' must be a snapshot ID or '%s'; only 'to' can be '%s'", snapshot.Current, Latest)), nil
+		}
+
+		result, err := Compare(ctx, store.Roots(), repoID, from, to)
+		if err != nil {
+			log.Printf("DIFF_CONTEXT tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		log.Printf("DIFF_CONTEXT tool: %s %s -> %s: %d added, %d removed, %d modified",
+			repoID, from, to, len(result.Added), len(result.Removed), len(result.Modified))
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(result)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(Format(result)), nil
+	})
+}
+
+// Compare loads versions from and to of the document of repo from roots and
+// compares them. A version is a snapshot ID or snapshot.Current; to can also
+// be Latest, which downloads the document again without saving it.
+func Compare(ctx context.Context, roots []string, repo store.Repo, from, to string) (*Result, error) {
+	fromPath, err := snapshot.Path(roots, repo, from)
+	if err != nil {
+		return nil, err
+	}
+	older, err := snippet.ParseFile(fromPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var newer *snippet.Document
+	if to == Latest {
+		// The current document records where to download it from
+		currentPath, err := snapshot.Path(roots, repo, snapshot.Current)
+		if err != nil {
+			return nil, err
+		}
+		document, err := savecontext.FetchLatest(ctx, repo, currentPath)
+		if err != nil {
+			return nil, err
+		}
+		newer = snippet.Parse(document.Content)
+	} else {
+		toPath, err := snapshot.Path(roots, repo, to)
+		if err != nil {
+			return nil, err
+		}
+		if newer, err = snippet.ParseFile(toPath); err != nil {
+			return nil, err
+		}
+	}
+
+	result := Documents(older, newer)
+	result.Repo, result.From, result.To = repo.String(), from, to
+	if to == Latest {
+		// A download has no header, so its lines do not match a stored file
+		for i := range result.Added {
+			result.Added[i].Line = 0
+		}
+		for i := range result.Modified {
+			result.Modified[i].ToLine = 0
+		}
+	}
+	return result, nil
+}
+
+// Documents compares the snippets of two versions of a document. Snippets
+// are matched by title and source; those left over are matched by title
+// alone when it is unique on both sides, so a topic that moved to another
+// source counts as modified rather than removed and added.
+func Documents(older, newer *snippet.Document) *Result {
+	result := &Result{Added: []Topic{}, Removed: []Topic{}, Modified: []Modification{}}
+
+	matched := make(map[int]int)
+	unmatched := make(map[int]bool, len(newer.Snippets))
+	for j := range newer.Snippets {
+		unmatched[j] = true
+	}
+	match := func(key func(*snippet.Snippet) string, unique bool) {
+		candidates := make(map[string][]int)
+		for j := range newer.Snippets {
+			if unmatched[j] {
+				k := key(&newer.Snippets[j])
+				candidates[k] = append(candidates[k], j)
+			}
+		}
+		counts := make(map[string]int)
+		if unique {
+			for i := range older.Snippets {
+				if _, ok := matched[i]; !ok {
+					counts[key(&older.Snippets[i])]++
+				}
+			}
+		}
+		for i := range older.Snippets {
+			if _, ok := matched[i]; ok {
+				continue
+			}
+			k := key(&older.Snippets[i])
+			if len(candidates[k]) == 0 || (unique && (counts[k] > 1 || len(candidates[k]) > 1)) {
+				continue
+			}
+			// Repeated keys are paired in document order
+			j := candidates[k][0]
+			candidates[k] = candidates[k][1:]
+			matched[i] = j
+			delete(unmatched, j)
+		}
+	}
+	match(func(s *snippet.Snippet) string { return s.Title + "\x00" + s.Source }, false)
+	match(func(s *snippet.Snippet) string { return s.Title }, true)
+
+	for i := range older.Snippets {
+		before := &older.Snippets[i]
+		j, ok := matched[i]
+		if !ok {
+			result.Removed = append(result.Removed, Topic{Title: before.Title, Source: before.Source, Line: before.TitleLine})
+			continue
+		}
+		after := &newer.Snippets[j]
+		changes := changedFields(before, after)
+		if len(changes) == 0 {
+			result.Unchanged++
+			continue
+		}
+		result.Modified = append(result.Modified, Modification{
+			Title:    after.Title,
+			Source:   after.Source,
+			Changes:  changes,
+			FromLine: before.TitleLine,
+			ToLine:   after.TitleLine,
+		})
+	}
+	for j := range newer.Snippets {
+		if unmatched[j] {
+			after := &newer.Snippets[j]
+			result.Added = append(result.Added, Topic{Title: after.Title, Source: after.Source, Line: after.TitleLine})
+		}
+	}
+	return result
+}
+
+// changedFields returns the fields that differ between two versions of a snippet
+func changedFields(before, after *snippet.Snippet) []string {
+	var changes []string
+	if strings.TrimSpace(before.Description) != strings.TrimSpace(after.Description) {
+		changes = append(changes, FieldDescription)
+	}
+	if before.Source != after.Source {
+		changes = append(changes, FieldSource)
+	}
+	if !sameExamples(before.Examples, after.Examples) {
+		changes = append(changes, FieldCode)
+	}
+	return changes
+}
+
+// sameExamples reports whether two snippets have the same code examples
+func sameExamples(a, b []snippet.Example) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Language != b[i].Language || strings.TrimSpace(a[i].Code) != strings.TrimSpace(b[i].Code) {
+			return false
+		}
+	}
+	return true
+}
+
+// Format renders a diff as text
+func Format(r *Result) string {
+	lines := []string{
+		fmt.Sprintf("=== Diff of %s: %s -> %s ===", r.Repo, r.From, r.To),
+		fmt.Sprintf("%d added, %d removed, %d modified, %d unchanged", len(r.Added), len(r.Removed), len(r.Modified), r.Unchanged),
+	}
+	if len(r.Added)+len(r.Removed)+len(r.Modified) == 0 {
+		return strings.Join(append(lines, "", "No topics changed."), "\n")
+	}
+
+	lines = append(lines, "")
+	for _, topic := range r.Added {
+		lines = append(lines, "+ "+describe(topic.Title, topic.Source, topic.Line))
+	}
+	for _, topic := range r.Removed {
+		lines = append(lines, "- "+describe(topic.Title, topic.Source, topic.Line))
+	}
+	for _, mod := range r.Modified {
+		line := mod.ToLine
+		if line == 0 {
+			line = mod.FromLine
+		}
+		lines = append(lines, fmt.Sprintf("~ %s: %s changed", describe(mod.Title, mod.Source, line), strings.Join(mod.Changes, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// describe renders a topic with its source and line, when known
+func describe(title, source string, line int) string {
+	text := title
+	if source != "" {
+		text += " (" + source + ")"
+	}
+	if line > 0 {
+		text += fmt.Sprintf(" [line %d]", line)
+	}
+	return text
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"docs4context-com/internal/snapshot"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+)
+
+// topics renders n snippets titled "Topic 1" to "Topic n"
+func topics(n int) string {
+	var blocks []string
+	for i := 1; i <= n; i++ {
+		blocks = append(blocks, fmt.Sprintf("TITLE: Topic %d\nDESCRIPTION: Describes topic %d\nSOURCE: https://example.com/%d\nLANGUAGE: go\nCODE:\n```\nfmt.Println(%d)\n```\n", i, i, i, i))
+	}
+	return strings.Join(blocks, "\n----------------------------------------\n\n")
+}
+
+func TestDocumentsShrunkenLatest(t *testing.T) {
+	older := snippet.Parse([]byte("# METADATA\n# TOKEN_COUNT: 500\n# REPO: a/b\n#\n" + topics(10)))
+	newer := snippet.Parse([]byte(topics(3)))
+
+	result := Documents(older, newer)
+	if result.Unchanged != 3 {
+		t.Errorf("unchanged = %d, want 3", result.Unchanged)
+	}
+	if len(result.Added) != 0 || len(result.Modified) != 0 {
+		t.Errorf("added = %v, modified = %v, want none", result.Added, result.Modified)
+	}
+	if len(result.Removed) != 7 {
+		t.Fatalf("removed = %v, want topics 4 to 10", result.Removed)
+	}
+	for i, topic := range result.Removed {
+		if want := fmt.Sprintf("Topic %d", i+4); topic.Title != want {
+			t.Errorf("removed[%d] = %q, want %q", i, topic.Title, want)
+		}
+	}
+}
+
+func TestDocumentsChanges(t *testing.T) {
+	older := snippet.Parse([]byte(`TITLE: Same
+DESCRIPTION: d
+SOURCE: s1
+----------------------------------------
+TITLE: Described
+DESCRIPTION: old
+SOURCE: s2
+----------------------------------------
+TITLE: Moved
+DESCRIPTION: m
+SOURCE: s3
+----------------------------------------
+TITLE: Gone
+DESCRIPTION: g
+SOURCE: s4
+`))
+	newer := snippet.Parse([]byte(`TITLE: Same
+DESCRIPTION: d
+SOURCE: s1
+----------------------------------------
+TITLE: Described
+DESCRIPTION: new
+SOURCE: s2
+----------------------------------------
+TITLE: Moved
+DESCRIPTION: m
+SOURCE: s3b
+----------------------------------------
+TITLE: New
+DESCRIPTION: n
+SOURCE: s5
+`))
+
+	result := Documents(older, newer)
+	if result.Unchanged != 1 {
+		t.Errorf("unchanged = %d, want 1", result.Unchanged)
+	}
+	if len(result.Added) != 1 || result.Added[0].Title != "New" {
+		t.Errorf("added = %v, want New", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].Title != "Gone" {
+		t.Errorf("removed = %v, want Gone", result.Removed)
+	}
+	want := map[string]string{"Described": FieldDescription, "Moved": FieldSource}
+	if len(result.Modified) != len(want) {
+		t.Fatalf("modified = %v, want %v", result.Modified, want)
+	}
+	for _, mod := range result.Modified {
+		if changes := strings.Join(mod.Changes, ","); changes != want[mod.Title] {
+			t.Errorf("changes of %s = %s, want %s", mod.Title, changes, want[mod.Title])
+		}
+	}
+}
+
+func TestCompareLatestRequestsFullContext7Document(t *testing.T) {
+	// The server cuts the document short unless the full size is requested,
+	// as context7 does for a request without a token limit
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/a/b/llms.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		count := 3
+		if tokens, _ := strconv.Atoi(r.URL.Query().Get("tokens")); tokens >= 500 {
+			count = 10
+		}
+		fmt.Fprint(w, topics(count))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "llm-context")
+	if err := store.Configure(store.Options{Root: root, GlobalRoot: filepath.Join(dir, "global"), ConfigPath: configPath, Context7URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	repo, err := store.ParseRepo("a/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A document saved before token limits were recorded in its SOURCE
+	path := repo.File(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	header := fmt.Sprintf("# METADATA\n# TOKEN_COUNT: 500\n# DATE_CREATED: 2025-01-01T00:00:00Z\n# REPO: a/b\n# SOURCE: %s/a/b/llms.txt\n#\n", srv.URL)
+	if err := os.WriteFile(path, []byte(header+topics(10)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Compare(context.Background(), []string{root}, repo, snapshot.Current, Latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Unchanged != 10 || len(result.Removed)+len(result.Added)+len(result.Modified) != 0 {
+		t.Errorf("diff against latest = %s, want 10 unchanged topics", Format(result))
+	}
+}
@@ -0,0 +1,349 @@
+package discover
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/manifest"
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// lookupConcurrency bounds the number of registry requests made at a time
+const lookupConcurrency = 8
+
+// skippedDirs are directories holding installed or generated files rather
+// than the project's own dependency files
+var skippedDirs = map[string]bool{
+	"node_modules": true,
+	"vendor":       true,
+	"target":       true,
+	"venv":         true,
+	"dist":         true,
+	"build":        true,
+	"__pycache__":  true,
+	"llm-context":  true,
+}
+
+// Dependency is a dependency declared in a dependency file
+type Dependency struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name"`
+	Version   string `json:"version,omitempty"`
+	File      string `json:"file"`
+}
+
+// Proposal is a repository whose document the project needs, with the
+// dependencies that led to it
+type Proposal struct {
+	Repo         string       `json:"repo"`
+	Dependencies []Dependency `json:"dependencies"`
+}
+
+// Unresolved is a dependency whose GitHub repository could not be found
+type Unresolved struct {
+	Dependency
+	Reason string `json:"reason"`
+}
+
+// Result is what was discovered in a directory tree
+type Result struct {
+	Dir        string       `json:"dir"`
+	Files      []string     `json:"files"`
+	Proposals  []Proposal   `json:"proposals"`
+	Unresolved []Unresolved `json:"unresolved,omitempty"`
+}
+
+// discoverResponse is the JSON form of discover_dependencies
+type discoverResponse struct {
+	*Result
+	Manifest *manifest.Manifest        `json:"manifest,omitempty"`
+	Saved    []savecontext.BatchResult `json:"saved,omitempty"`
+}
+
+// AddDiscoverTool adds the tool finding the documents a project depends on
+func AddDiscoverTool(s *server.MCPServer) {
+	discoverTool := mcp.NewTool("discover_dependencies",
+		mcp.WithDescription("Find the dependencies declared in go.mod, package.json, Cargo.toml, pyproject.toml and requirements.txt files under a directory, map them to GitHub repositories and propose their context documents, or download them"),
+		mcp.WithString("path",
+			mcp.Description("Directory to search for dependency files (default: the working directory)"),
+		),
+		mcp.WithBoolean("run",
+			mcp.Description("Download the documents of the discovered repositories instead of only proposing them"),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of documents to download at a time with 'run' (default %d, at most %d)", savecontext.DefaultConcurrency, savecontext.MaxConcurrency)),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Store to save to with 'run': 'project' (default) or 'global'"),
+			mcp.Enum(store.ScopeProject, store.ScopeGlobal),
+		),
+		mcp.WithString("output_dir",
+			mcp.Description("Output directory for the documents with 'run', overriding 'scope'"),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(discoverTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("DISCOVER_DEPENDENCIES tool called")
+
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("DISCOVER_DEPENDENCIES tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		concurrency := request.GetInt("concurrency", savecontext.DefaultConcurrency)
+		if concurrency < 1 || concurrency > savecontext.MaxConcurrency {
+			log.Printf("DISCOVER_DEPENDENCIES tool error - invalid parameter 'concurrency': %d", concurrency)
+			return mcp.NewToolResultError(fmt.Sprintf("concurrency must be between 1 and %d", savecontext.MaxConcurrency)), nil
+		}
+
+		run := request.GetBool("run", false)
+		outputDir := ""
+		if run {
+			outputDir, err = savecontext.ResolveOutputDir(request.GetString("output_dir", ""), request.GetString("scope", store.DefaultScope))
+			if err != nil {
+				log.Printf("DISCOVER_DEPENDENCIES tool error - invalid output location: %v", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		result, err := Discover(ctx, request.GetString("path", "."))
+		if err != nil {
+			log.Printf("DISCOVER_DEPENDENCIES tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		log.Printf("DISCOVER_DEPENDENCIES tool: %d repositories, %d unresolved dependencies", len(result.Proposals), len(result.Unresolved))
+
+		response := discoverResponse{Result: result}
+		if run {
+			response.Saved = Run(ctx, result, outputDir, concurrency)
+		} else {
+			response.Manifest = result.Manifest()
+		}
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(response)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		if run {
+			return mcp.NewToolResultText(FormatRun(result, response.Saved)), nil
+		}
+		text, err := FormatProposals(result)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(text), nil
+	})
+}
+
+// Discover parses the dependency files under dir and maps their
+// dependencies to GitHub repositories
+func Discover(ctx context.Context, dir string) (*Result, error) {
+	deps, files, err := Scan(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{Dir: dir, Files: files, Proposals: []Proposal{}}
+
+	// Look each package up once, however many files declare it
+	type pkg struct{ ecosystem, name string }
+	var unique []pkg
+	seen := make(map[pkg]bool)
+	for _, dep := range deps {
+		key := pkg{dep.Ecosystem, dep.Name}
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+
+	repos := make([]store.Repo, len(unique))
+	errs := make([]error, len(unique))
+	r := newResolver()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < lookupConcurrency && worker < len(unique); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				repos[i], errs[i] = r.resolve(ctx, Dependency{Ecosystem: unique[i].ecosystem, Name: unique[i].name})
+			}
+		}()
+	}
+	for i := range unique {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	resolved := make(map[pkg]int, len(unique))
+	for i, key := range unique {
+		resolved[key] = i
+	}
+	byRepo := make(map[string]int)
+	for _, dep := range deps {
+		i := resolved[pkg{dep.Ecosystem, dep.Name}]
+		if errs[i] != nil {
+			result.Unresolved = append(result.Unresolved, Unresolved{Dependency: dep, Reason: errs[i].Error()})
+			continue
+		}
+		name := repos[i].String()
+		j, ok := byRepo[name]
+		if !ok {
+			j = len(result.Proposals)
+			byRepo[name] = j
+			result.Proposals = append(result.Proposals, Proposal{Repo: name})
+		}
+		result.Proposals[j].Dependencies = append(result.Proposals[j].Dependencies, dep)
+	}
+	sort.Slice(result.Proposals, func(a, b int) bool {
+		return result.Proposals[a].Repo < result.Proposals[b].Repo
+	})
+
+	return result, nil
+}
+
+// Scan returns the dependencies declared in the dependency files under dir,
+// and the files they were found in relative to dir
+func Scan(dir string) ([]Dependency, []string, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	var deps []Dependency
+	var files []string
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && (skippedDirs[entry.Name()] || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		parse, ok := parsers[entry.Name()]
+		if !ok || !entry.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", rel, err)
+		}
+		found, err := parse(content)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", rel, err)
+		}
+		for _, dep := range found {
+			dep.File = rel
+			deps = append(deps, dep)
+		}
+		files = append(files, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return deps, files, nil
+}
+
+// Manifest returns the project manifest listing the proposed repositories
+func (r *Result) Manifest() *manifest.Manifest {
+	m := &manifest.Manifest{Documents: []manifest.Entry{}}
+	for _, proposal := range r.Proposals {
+		m.Documents = append(m.Documents, manifest.Entry{Repo: proposal.Repo})
+	}
+	return m
+}
+
+// Run saves the documents of the proposed repositories into outputDir with
+// at most concurrency downloads at a time
+func Run(ctx context.Context, r *Result, outputDir string, concurrency int) []savecontext.BatchResult {
+	if len(r.Proposals) == 0 {
+		return nil
+	}
+	targets := make([]string, 0, len(r.Proposals))
+	for _, proposal := range r.Proposals {
+		targets = append(targets, proposal.Repo)
+	}
+	return savecontext.Batch(ctx, savecontext.Requests(targets, savecontext.Request{OutputDir: outputDir}), concurrency)
+}
+
+// FormatProposals renders the discovered repositories as text, followed by
+// the manifest listing them
+func FormatProposals(r *Result) (string, error) {
+	lines := formatResult(r)
+	if len(r.Proposals) > 0 {
+		text, err := format.Marshal(r.Manifest())
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, "", fmt.Sprintf("Add these to %s and run sync_context_documents, or discover again with run to download them:", manifest.DefaultFile), text)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// FormatRun renders the discovered repositories and their downloads as text
+func FormatRun(r *Result, saved []savecontext.BatchResult) string {
+	lines := formatResult(r)
+	if len(saved) > 0 {
+		lines = append(lines, "", savecontext.FormatBatch(saved))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatResult renders the repositories and unresolved dependencies of a result
+func formatResult(r *Result) []string {
+	deps := len(r.Unresolved)
+	for _, proposal := range r.Proposals {
+		deps += len(proposal.Dependencies)
+	}
+	lines := []string{fmt.Sprintf("=== Discovered %d repositories from %d dependencies in %d files ===", len(r.Proposals), deps, len(r.Files)), ""}
+	if len(r.Files) == 0 {
+		return append(lines, fmt.Sprintf("No go.mod, package.json, Cargo.toml, pyproject.toml or requirements.txt found under %s.", r.Dir))
+	}
+
+	for _, proposal := range r.Proposals {
+		var from []string
+		for _, dep := range proposal.Dependencies {
+			from = append(from, fmt.Sprintf("%s %s in %s", dep.Ecosystem, dep.Name, dep.File))
+		}
+		lines = append(lines, fmt.Sprintf("+ %s (%s)", proposal.Repo, strings.Join(from, ", ")))
+	}
+	if len(r.Unresolved) > 0 {
+		lines = append(lines, "", "Unresolved dependencies:")
+		for _, dep := range r.Unresolved {
+			lines = append(lines, fmt.Sprintf("? %s %s in %s: %s", dep.Ecosystem, dep.Name, dep.File, dep.Reason))
+		}
+	}
+	return lines
+}
@@ -0,0 +1,303 @@
+package discover
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Ecosystems of the dependency files
+const (
+	EcosystemGo     = "go"
+	EcosystemNPM    = "npm"
+	EcosystemCargo  = "cargo"
+	EcosystemPython = "python"
+)
+
+// parsers maps the dependency files that are recognised to their parser
+var parsers = map[string]func(content []byte) ([]Dependency, error){
+	"go.mod":           parseGoMod,
+	"package.json":     parsePackageJSON,
+	"Cargo.toml":       parseCargoToml,
+	"pyproject.toml":   parsePyproject,
+	"requirements.txt": parseRequirements,
+}
+
+// parseGoMod returns the direct requirements of a go.mod file
+func parseGoMod(content []byte) ([]Dependency, error) {
+	var deps []Dependency
+	inBlock := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		indirect := strings.Contains(line, "// indirect")
+		if comment := strings.Index(line, "//"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+
+		switch {
+		case line == "require (":
+			inBlock = true
+			continue
+		case inBlock && line == ")":
+			inBlock = false
+			continue
+		case strings.HasPrefix(line, "require "):
+			line = strings.TrimSpace(strings.TrimPrefix(line, "require "))
+		case !inBlock:
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && !indirect {
+			deps = append(deps, Dependency{Ecosystem: EcosystemGo, Name: fields[0], Version: fields[1]})
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// parsePackageJSON returns the dependencies and devDependencies of a package.json file
+func parsePackageJSON(content []byte) ([]Dependency, error) {
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(content, &pkg); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	var deps []Dependency
+	for _, group := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+		for _, name := range sortedKeys(group) {
+			deps = append(deps, Dependency{Ecosystem: EcosystemNPM, Name: name, Version: group[name]})
+		}
+	}
+	return deps, nil
+}
+
+// parseCargoToml returns the crates of the dependency tables of a Cargo.toml file
+func parseCargoToml(content []byte) ([]Dependency, error) {
+	var deps []Dependency
+	tables := make(map[string]int)
+	for _, entry := range tomlTables(content, func(table string) bool {
+		return strings.HasSuffix(table, "dependencies") || cargoDependencyTable(table) != ""
+	}) {
+		name := cargoDependencyTable(entry.table)
+		if name == "" {
+			deps = append(deps, Dependency{Ecosystem: EcosystemCargo, Name: entry.key, Version: tomlVersion(entry.value)})
+			continue
+		}
+		i, ok := tables[entry.table]
+		if !ok {
+			i = len(deps)
+			tables[entry.table] = i
+			deps = append(deps, Dependency{Ecosystem: EcosystemCargo, Name: name})
+		}
+		if entry.key == "version" {
+			deps[i].Version = tomlVersion(entry.value)
+		}
+	}
+	return deps, nil
+}
+
+// cargoDependencyTable returns the dependency a table such as
+// [dependencies.serde] declares on its own, or "" for other tables
+func cargoDependencyTable(table string) string {
+	i := strings.LastIndex(table, ".")
+	if i < 0 || !strings.HasSuffix(table[:i], "dependencies") {
+		return ""
+	}
+	return strings.Trim(table[i+1:], `"'`)
+}
+
+var (
+	// pep508NameRegex matches the distribution name at the start of a requirement
+	pep508NameRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*(.*)$`)
+	// quotedRegex matches a quoted TOML string
+	quotedRegex = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+)
+
+// parsePyproject returns the requirements of a pyproject.toml file, from the
+// PEP 621 dependencies array and from Poetry's dependency tables
+func parsePyproject(content []byte) ([]Dependency, error) {
+	var deps []Dependency
+
+	// The dependencies array of the [project] table, possibly spanning lines
+	section := ""
+	var array []string
+	inArray := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripTomlComment(scanner.Text()))
+		if inArray {
+			array = append(array, line)
+			inArray = !closesArray(line)
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			section = strings.Trim(line, "[] ")
+			continue
+		}
+		if section == "project" && strings.HasPrefix(line, "dependencies") {
+			_, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+			array = append(array, value)
+			inArray = !closesArray(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	for _, requirement := range quotedStrings(strings.Join(array, " ")) {
+		if dep, ok := parseRequirement(requirement); ok {
+			deps = append(deps, dep)
+		}
+	}
+
+	for _, entry := range tomlTables(content, func(table string) bool {
+		return strings.HasPrefix(table, "tool.poetry.") && strings.HasSuffix(table, "dependencies")
+	}) {
+		if entry.key != "python" {
+			deps = append(deps, Dependency{Ecosystem: EcosystemPython, Name: entry.key, Version: tomlVersion(entry.value)})
+		}
+	}
+	return deps, nil
+}
+
+// parseRequirements returns the requirements of a requirements.txt file,
+// skipping options such as -r and -e
+func parseRequirements(content []byte) ([]Dependency, error) {
+	var deps []Dependency
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment := strings.Index(line, " #"); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+		// Options such as --hash continue a requirement on the next lines
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\\"))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") {
+			continue
+		}
+		if dep, ok := parseRequirement(line); ok {
+			deps = append(deps, dep)
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// parseRequirement parses a PEP 508 requirement such as "requests[socks]>=2.31".
+// A direct reference such as "name @ https://..." has no version, and a bare
+// URL such as "git+https://..." names no distribution.
+func parseRequirement(requirement string) (Dependency, bool) {
+	match := pep508NameRegex.FindStringSubmatch(strings.TrimSpace(requirement))
+	if match == nil {
+		return Dependency{}, false
+	}
+	if strings.HasPrefix(match[2], "@") {
+		return Dependency{Ecosystem: EcosystemPython, Name: match[1]}, true
+	}
+	if strings.Contains(requirement, "://") {
+		return Dependency{}, false
+	}
+	version, _, _ := strings.Cut(match[2], ";")
+	return Dependency{Ecosystem: EcosystemPython, Name: match[1], Version: strings.TrimSpace(version)}, true
+}
+
+// tomlEntry is a "key = value" line of a TOML table
+type tomlEntry struct {
+	table string
+	key   string
+	value string
+}
+
+// tomlTables returns the entries of the TOML tables selected by include.
+// This is not a full TOML parser; it reads the single-line "key = value"
+// entries that dependency tables consist of.
+func tomlTables(content []byte, include func(table string) bool) []tomlEntry {
+	var entries []tomlEntry
+	table := ""
+	selected := false
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(stripTomlComment(scanner.Text()))
+		if strings.HasPrefix(line, "[") {
+			table = strings.Trim(line, "[] ")
+			selected = include(table)
+			continue
+		}
+		if !selected {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"'`)
+		if key != "" && !strings.ContainsAny(key, ". ") {
+			entries = append(entries, tomlEntry{table: table, key: key, value: strings.TrimSpace(value)})
+		}
+	}
+	return entries
+}
+
+// tomlVersion returns the version of a dependency value: "1.0" or { version = "1.0", ... }
+func tomlVersion(value string) string {
+	if strings.HasPrefix(value, "{") {
+		for _, part := range strings.Split(strings.Trim(value, "{}"), ",") {
+			key, version, ok := strings.Cut(part, "=")
+			if ok && strings.TrimSpace(key) == "version" {
+				return strings.Trim(strings.TrimSpace(version), `"'`)
+			}
+		}
+		return ""
+	}
+	return strings.Trim(value, `"'`)
+}
+
+// stripTomlComment removes a trailing comment outside of quoted strings
+func stripTomlComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote == 0 && (r == '"' || r == '\''):
+			quote = r
+		case quote == 0 && r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// closesArray reports whether a line of a TOML array holds its closing
+// bracket, ignoring brackets in strings such as "requests[socks]"
+func closesArray(line string) bool {
+	return strings.Contains(quotedRegex.ReplaceAllString(line, ""), "]")
+}
+
+// quotedStrings returns the quoted strings of a TOML array
+func quotedStrings(array string) []string {
+	var values []string
+	for _, match := range quotedRegex.FindAllStringSubmatch(array, -1) {
+		values = append(values, match[1]+match[2])
+	}
+	return values
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,274 @@
+package discover
+
+import (
+	"reflect"
+	"testing"
+)
+
+// dependencies renders dependencies as "name version" pairs
+func dependencies(deps []Dependency) []string {
+	var rendered []string
+	for _, dep := range deps {
+		rendered = append(rendered, dep.Name+" "+dep.Version)
+	}
+	return rendered
+}
+
+func TestParseGoMod(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    []string
+	}{
+		"modules with indirect and tool blocks": {`module github.com/example/server
+
+go 1.24.2
+
+toolchain go1.24.4
+
+require (
+	github.com/mark3labs/mcp-go v0.38.0
+	github.com/pkoukk/tiktoken-go v0.1.7 // pinned for the encoder
+	golang.org/x/sync v0.14.0
+)
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+)
+
+require gopkg.in/yaml.v3 v3.0.1
+
+require golang.org/x/text v0.25.0 // indirect
+
+replace (
+	github.com/example/fork => ../fork
+	github.com/example/old v1.0.0 => github.com/example/new v1.1.0
+)
+
+exclude github.com/example/broken v1.2.3
+
+tool (
+	golang.org/x/tools/cmd/stringer
+)
+
+retract v1.0.1 // published by mistake
+`, []string{
+			"github.com/mark3labs/mcp-go v0.38.0",
+			"github.com/pkoukk/tiktoken-go v0.1.7",
+			"golang.org/x/sync v0.14.0",
+			"gopkg.in/yaml.v3 v3.0.1",
+		}},
+		"no requirements":      {"module example.com/m\n\ngo 1.22\n", nil},
+		"windows line endings": {"module m\r\n\r\nrequire (\r\n\tgithub.com/a/b v1.0.0\r\n)\r\n", []string{"github.com/a/b v1.0.0"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			deps, err := parseGoMod([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dependencies(deps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseGoMod = %q, want %q", got, tt.want)
+			}
+			for _, dep := range deps {
+				if dep.Ecosystem != EcosystemGo {
+					t.Errorf("ecosystem of %s = %s, want go", dep.Name, dep.Ecosystem)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePackageJSON(t *testing.T) {
+	content := `{
+  "name": "web",
+  "private": true,
+  "scripts": { "dev": "next dev" },
+  "dependencies": {
+    "react": "^19.0.0",
+    "next": "15.3.2",
+    "@tanstack/react-query": "^5.76.1"
+  },
+  "devDependencies": {
+    "typescript": "~5.8.3"
+  },
+  "peerDependencies": {
+    "react-dom": "*"
+  }
+}`
+	deps, err := parsePackageJSON([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"@tanstack/react-query ^5.76.1", "next 15.3.2", "react ^19.0.0", "typescript ~5.8.3"}
+	if got := dependencies(deps); !reflect.DeepEqual(got, want) {
+		t.Errorf("parsePackageJSON = %q, want %q", got, want)
+	}
+
+	if _, err := parsePackageJSON([]byte(`{"dependencies": `)); err == nil {
+		t.Error("parsePackageJSON of invalid JSON succeeded")
+	}
+}
+
+func TestTomlTables(t *testing.T) {
+	content := `[package]
+name = "demo" # the crate name
+version = "0.1.0"
+
+[dependencies]
+serde = "1.0"  # comment
+"quoted-key" = '2'
+a.b = "dotted keys are skipped"
+url = { git = "https://example.com/a#b" }
+
+[dev-dependencies]
+# only a comment
+tokio = { version = "1", features = ["full"] }
+`
+	entries := tomlTables([]byte(content), func(table string) bool {
+		return table == "dependencies" || table == "dev-dependencies"
+	})
+	want := []tomlEntry{
+		{table: "dependencies", key: "serde", value: `"1.0"`},
+		{table: "dependencies", key: "quoted-key", value: `'2'`},
+		{table: "dependencies", key: "url", value: `{ git = "https://example.com/a#b" }`},
+		{table: "dev-dependencies", key: "tokio", value: `{ version = "1", features = ["full"] }`},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("tomlTables = %+v\nwant %+v", entries, want)
+	}
+
+	versions := map[string]string{
+		`"1.0"`:                                  "1.0",
+		`'2'`:                                    "2",
+		`{ version = "1", features = ["full"] }`: "1",
+		`{ features = ["a", "b"], version = "0.4" }`: "0.4",
+		`{ path = "../local" }`:                      "",
+	}
+	for value, want := range versions {
+		if got := tomlVersion(value); got != want {
+			t.Errorf("tomlVersion(%s) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestParseCargoToml(t *testing.T) {
+	content := `[package]
+name = "demo"
+version = "0.1.0"
+edition = "2021"
+
+[dependencies]
+anyhow = "1.0.98"
+serde = { version = "1.0", features = ["derive"] }
+local = { path = "../local" }
+
+[dependencies.tokio]
+version = "1.45"
+features = ["full"]
+
+[dev-dependencies]
+insta = "1.43"
+
+[build-dependencies]
+cc = "1.2"
+
+[target.'cfg(windows)'.dependencies]
+windows-sys = "0.59"
+
+[features]
+default = ["serde"]
+
+[profile.release]
+lto = true
+`
+	deps, err := parseCargoToml([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"anyhow 1.0.98", "serde 1.0", "local ", "tokio 1.45", "insta 1.43", "cc 1.2", "windows-sys 0.59"}
+	if got := dependencies(deps); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseCargoToml = %q, want %q", got, want)
+	}
+}
+
+func TestParsePyproject(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    []string
+	}{
+		"PEP 621": {`[build-system]
+requires = ["hatchling"]
+build-backend = "hatchling.build"
+
+[project]
+name = "service"
+requires-python = ">=3.10"
+dependencies = [
+    "fastapi>=0.115",   # web framework
+    "uvicorn[standard]>=0.34",
+    'httpx',
+    "pydantic-settings~=2.9; python_version >= '3.10'",
+]
+
+[project.optional-dependencies]
+dev = ["pytest"]
+
+[tool.ruff]
+line-length = 100
+`, []string{"fastapi >=0.115", "uvicorn >=0.34", "httpx ", "pydantic-settings ~=2.9"}},
+		"single line array": {`[project]
+name = "cli"
+dependencies = ["click>=8", "rich"]
+`, []string{"click >=8", "rich "}},
+		"Poetry": {`[tool.poetry]
+name = "app"
+
+[tool.poetry.dependencies]
+python = "^3.11"
+django = "^5.2"
+celery = { version = "^5.5", extras = ["redis"] }
+
+[tool.poetry.group.dev.dependencies]
+pytest = "^8.3"
+`, []string{"django ^5.2", "celery ^5.5", "pytest ^8.3"}},
+		"no dependencies": {"[project]\nname = \"empty\"\ndependencies = []\n", nil},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			deps, err := parsePyproject([]byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := dependencies(deps); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePyproject = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRequirements(t *testing.T) {
+	content := `# Production requirements
+-r base.txt
+--index-url https://pypi.org/simple
+-e git+https://github.com/example/editable.git#egg=editable
+Django==5.2.1
+requests[socks]>=2.32,<3  # HTTP
+numpy ; python_version >= "3.10"
+gunicorn==23.0.0 \
+    --hash=sha256:0123456789abcdef
+internal-lib @ https://example.com/internal_lib-1.0-py3-none-any.whl
+git+https://github.com/example/vcs-only.git
+https://example.com/archive.tar.gz
+
+black
+`
+	deps, err := parseRequirements([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Django ==5.2.1", "requests >=2.32,<3", "numpy ", "gunicorn ==23.0.0", "internal-lib ", "black "}
+	if got := dependencies(deps); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRequirements = %q, want %q", got, want)
+	}
+}
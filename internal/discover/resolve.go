@@ -0,0 +1,176 @@
+package discover
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"docs4context-com/internal/store"
+)
+
+// Package registries consulted for the repository of a dependency
+const (
+	npmRegistry    = "https://registry.npmjs.org"
+	pypiRegistry   = "https://pypi.org/pypi"
+	cratesRegistry = "https://crates.io/api/v1/crates"
+)
+
+// lookupTimeout bounds a single registry request
+const lookupTimeout = 10 * time.Second
+
+// githubRepoRegex finds a GitHub repository in a URL such as
+// git+https://github.com/owner/repo.git or git@github.com:owner/repo
+var githubRepoRegex = regexp.MustCompile(`github\.com[/:]([A-Za-z0-9][A-Za-z0-9_.-]*)/([A-Za-z0-9][A-Za-z0-9_.-]*?)(?:\.git)?(?:[/#?]|$)`)
+
+// goVanityRepos maps module path prefixes hosted outside GitHub to the
+// GitHub organisation mirroring them
+var goVanityRepos = map[string]string{
+	"golang.org/x/":              "golang",
+	"google.golang.org/grpc":     "grpc/grpc-go",
+	"google.golang.org/protobuf": "protocolbuffers/protobuf-go",
+	"gopkg.in/yaml.":             "go-yaml/yaml",
+}
+
+// resolver looks up the GitHub repository of dependencies
+type resolver struct {
+	client    *http.Client
+	npmURL    string
+	pypiURL   string
+	cratesURL string
+}
+
+// newResolver creates a resolver using the public package registries
+func newResolver() *resolver {
+	return &resolver{
+		client:    &http.Client{Timeout: lookupTimeout},
+		npmURL:    npmRegistry,
+		pypiURL:   pypiRegistry,
+		cratesURL: cratesRegistry,
+	}
+}
+
+// resolve returns the GitHub repository of a dependency
+func (r *resolver) resolve(ctx context.Context, dep Dependency) (store.Repo, error) {
+	switch dep.Ecosystem {
+	case EcosystemGo:
+		return resolveGoModule(dep.Name)
+	case EcosystemNPM:
+		var pkg struct {
+			Repository json.RawMessage `json:"repository"`
+			Homepage   string          `json:"homepage"`
+		}
+		if err := r.getJSON(ctx, r.npmURL+"/"+strings.Replace(url.PathEscape(dep.Name), "%40", "@", 1), &pkg); err != nil {
+			return store.Repo{}, err
+		}
+		var repository struct {
+			URL string `json:"url"`
+		}
+		var shorthand string
+		if json.Unmarshal(pkg.Repository, &repository) != nil && json.Unmarshal(pkg.Repository, &shorthand) == nil {
+			repository.URL = expandShorthand(shorthand)
+		}
+		return githubRepo(repository.URL, pkg.Homepage)
+	case EcosystemPython:
+		var pkg struct {
+			Info struct {
+				HomePage    string            `json:"home_page"`
+				ProjectURLs map[string]string `json:"project_urls"`
+			} `json:"info"`
+		}
+		if err := r.getJSON(ctx, r.pypiURL+"/"+url.PathEscape(dep.Name)+"/json", &pkg); err != nil {
+			return store.Repo{}, err
+		}
+		candidates := []string{pkg.Info.ProjectURLs["Source"], pkg.Info.ProjectURLs["Repository"], pkg.Info.ProjectURLs["Source Code"]}
+		for _, key := range sortedKeys(pkg.Info.ProjectURLs) {
+			candidates = append(candidates, pkg.Info.ProjectURLs[key])
+		}
+		return githubRepo(append(candidates, pkg.Info.HomePage)...)
+	case EcosystemCargo:
+		var pkg struct {
+			Crate struct {
+				Repository string `json:"repository"`
+				Homepage   string `json:"homepage"`
+			} `json:"crate"`
+		}
+		if err := r.getJSON(ctx, r.cratesURL+"/"+url.PathEscape(dep.Name), &pkg); err != nil {
+			return store.Repo{}, err
+		}
+		return githubRepo(pkg.Crate.Repository, pkg.Crate.Homepage)
+	}
+	return store.Repo{}, fmt.Errorf("unknown ecosystem %q", dep.Ecosystem)
+}
+
+// resolveGoModule maps a Go module path to its GitHub repository without
+// network access: github.com paths directly, and well-known vanity paths
+func resolveGoModule(module string) (store.Repo, error) {
+	if strings.HasPrefix(module, "github.com/") {
+		parts := strings.Split(module, "/")
+		if len(parts) >= 3 {
+			return store.ParseRepo(parts[1] + "/" + parts[2])
+		}
+	}
+	for prefix, repo := range goVanityRepos {
+		if !strings.HasPrefix(module, prefix) {
+			continue
+		}
+		if !strings.Contains(repo, "/") {
+			// An organisation: the first element after the prefix is the repository
+			name, _, _ := strings.Cut(strings.TrimPrefix(module, prefix), "/")
+			repo += "/" + name
+		}
+		return store.ParseRepo(repo)
+	}
+	return store.Repo{}, fmt.Errorf("no GitHub repository known for module %s", module)
+}
+
+// getJSON decodes the JSON document at url into v
+func (r *resolver) getJSON(ctx context.Context, rawURL string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	// crates.io rejects requests without a User-Agent
+	req.Header.Set("User-Agent", "docs4context (https://github.com/jasonwillschiu/docs4context-com)")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("registry lookup failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("registry lookup failed, status: %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("invalid registry response: %v", err)
+	}
+	return nil
+}
+
+// githubRepo returns the first GitHub repository found among candidate URLs
+func githubRepo(candidates ...string) (store.Repo, error) {
+	for _, candidate := range candidates {
+		if match := githubRepoRegex.FindStringSubmatch(candidate); match != nil {
+			if repo, err := store.ParseRepo(match[1] + "/" + match[2]); err == nil {
+				return repo, nil
+			}
+		}
+	}
+	return store.Repo{}, fmt.Errorf("the registry lists no GitHub repository")
+}
+
+// expandShorthand turns npm's "github:owner/repo" and "owner/repo" repository
+// shorthands into URLs
+func expandShorthand(shorthand string) string {
+	shorthand = strings.TrimPrefix(shorthand, "github:")
+	if !strings.Contains(shorthand, ":") && strings.Count(shorthand, "/") == 1 {
+		return "https://github.com/" + shorthand
+	}
+	return shorthand
+}
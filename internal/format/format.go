@@ -0,0 +1,48 @@
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Output formats accepted by the tools
+const (
+	Text = "text"
+	JSON = "json"
+)
+
+// Param documents the format parameter for tool descriptions
+const Param = "Output format: 'text' (default) for readable results or 'json' for machine-readable results"
+
+// FromRequest reads and validates the format parameter of a tool call
+func FromRequest(request mcp.CallToolRequest) (string, error) {
+	switch format := request.GetString("format", Text); format {
+	case Text, JSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("invalid format %q: expected 'text' or 'json'", format)
+	}
+}
+
+// Marshal renders a tool result as indented JSON
+func Marshal(v any) (string, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to encode result: %v", err)
+	}
+	return string(data), nil
+}
+
+// Result returns the result of a tool call rendered as text in outputFormat.
+// A JSON result is also sent as structured content, with the text as the
+// fallback for clients that do not support it. Structured content must be a
+// JSON object, so any other text is sent as text only.
+func Result(outputFormat, text string) *mcp.CallToolResult {
+	var object map[string]json.RawMessage
+	if outputFormat != JSON || json.Unmarshal([]byte(text), &object) != nil {
+		return mcp.NewToolResultText(text)
+	}
+	return mcp.NewToolResultStructured(json.RawMessage(text), text)
+}
@@ -0,0 +1,134 @@
+package index
+
+import (
+	"math"
+	"sort"
+
+	"docs4context-com/internal/snippet"
+)
+
+// BM25 tuning parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// fieldWeights boosts matches in titles and descriptions over matches in code
+var fieldWeights = [numFields]float64{3.0, 2.0, 1.0}
+
+// Hit is a scored snippet
+type Hit struct {
+	Ref
+	Score float64
+}
+
+// buildStats computes the average field lengths used for length normalisation
+func (ix *Index) buildStats() {
+	var totals [numFields]int
+	ix.snippetCount = 0
+	for _, doc := range ix.Documents {
+		for _, snip := range doc.Snippets {
+			for field := 0; field < numFields; field++ {
+				totals[field] += snip.FieldLengths[field]
+			}
+			ix.snippetCount++
+		}
+	}
+	for field := 0; field < numFields; field++ {
+		ix.avgFieldLength[field] = 0
+		if ix.snippetCount > 0 {
+			ix.avgFieldLength[field] = float64(totals[field]) / float64(ix.snippetCount)
+		}
+	}
+}
+
+// ScoreTerm is a query term to score, searched in the given fields
+type ScoreTerm struct {
+	Text   string
+	Fields snippet.FieldMask
+}
+
+// Scorer scores snippets against a fixed set of query terms with BM25F
+type Scorer struct {
+	terms []scoredTerm
+}
+
+type scoredTerm struct {
+	idf float64
+	tf  map[Ref]float64
+}
+
+// NewScorer prepares BM25F scoring of the given terms. Each term is split
+// like document text and every part matches any indexed term it is a
+// substring of, so "recov" contributes the frequencies of "recovery".
+func (ix *Index) NewScorer(terms []ScoreTerm) *Scorer {
+	scorer := &Scorer{}
+	for _, term := range terms {
+		for _, queryTerm := range Tokenize(term.Text) {
+			weighted := ix.weightedFrequencies(queryTerm, term.Fields)
+			if len(weighted) == 0 {
+				continue
+			}
+			n := float64(len(weighted))
+			scorer.terms = append(scorer.terms, scoredTerm{
+				idf: math.Log(1 + (float64(ix.snippetCount)-n+0.5)/(n+0.5)),
+				tf:  weighted,
+			})
+		}
+	}
+	return scorer
+}
+
+// Score returns the BM25F score of a snippet, zero when no term occurs in it
+func (s *Scorer) Score(ref Ref) float64 {
+	var score float64
+	for _, term := range s.terms {
+		if tf, ok := term.tf[ref]; ok {
+			score += term.idf * tf / (bm25K1 + tf)
+		}
+	}
+	return score
+}
+
+// weightedFrequencies sums the length-normalised, field-weighted frequency of
+// every indexed term containing queryTerm, per snippet
+func (ix *Index) weightedFrequencies(queryTerm string, fields snippet.FieldMask) map[Ref]float64 {
+	weighted := make(map[Ref]float64)
+
+	for _, term := range ix.containing(queryTerm) {
+		for _, p := range ix.Postings[term] {
+			if !p.inFields(fields) {
+				continue
+			}
+			lengths := ix.Documents[p.Doc].Snippets[p.Snippet].FieldLengths
+
+			var tf float64
+			for field := 0; field < numFields; field++ {
+				if fields&(1<<field) == 0 || p.Freq[field] == 0 {
+					continue
+				}
+				norm := 1.0
+				if ix.avgFieldLength[field] > 0 {
+					norm = 1 - bm25B + bm25B*float64(lengths[field])/ix.avgFieldLength[field]
+				}
+				tf += fieldWeights[field] * float64(p.Freq[field]) / norm
+			}
+			weighted[Ref{Doc: p.Doc, Snippet: p.Snippet}] += tf
+		}
+	}
+
+	return weighted
+}
+
+// SortHits orders hits by descending score, then by document and snippet
+func SortHits(hits []Hit) {
+	sort.Slice(hits, func(a, b int) bool {
+		if hits[a].Score != hits[b].Score {
+			return hits[a].Score > hits[b].Score
+		}
+		if hits[a].Doc != hits[b].Doc {
+			return hits[a].Doc < hits[b].Doc
+		}
+		return hits[a].Snippet < hits[b].Snippet
+	})
+}
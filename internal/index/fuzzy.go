@@ -0,0 +1,99 @@
+package index
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"docs4context-com/internal/snippet"
+)
+
+// FuzzyMatch is the best fuzzy match of a query word within a snippet
+type FuzzyMatch struct {
+	Term       string
+	Similarity float64
+}
+
+// FuzzyLookup finds the snippets with an indexed term in the selected fields
+// whose similarity to word is at least threshold, keeping the most similar
+// term per snippet. A term containing word counts as an exact match.
+func (ix *Index) FuzzyLookup(word string, fields snippet.FieldMask, threshold float64) map[Ref]FuzzyMatch {
+	matches := make(map[Ref]FuzzyMatch)
+	word = strings.ToLower(word)
+
+	add := func(term string, similarity float64) {
+		for _, p := range ix.Postings[term] {
+			if !p.inFields(fields) {
+				continue
+			}
+			ref := Ref{Doc: p.Doc, Snippet: p.Snippet}
+			if best, ok := matches[ref]; !ok || similarity > best.Similarity {
+				matches[ref] = FuzzyMatch{Term: term, Similarity: similarity}
+			}
+		}
+	}
+
+	for _, term := range ix.containing(word) {
+		add(term, 1)
+	}
+
+	// Other terms can only reach the threshold when their length is close
+	// enough to that of word: between threshold times its length and its
+	// length divided by threshold
+	wordLen := utf8.RuneCountInString(word)
+	shortest := max(int(math.Floor(threshold*float64(wordLen))), 0)
+	longest := len(ix.lengths) - 1
+	if threshold > 0 {
+		longest = min(int(math.Ceil(float64(wordLen)/threshold)), longest)
+	}
+	for length := shortest; length <= longest; length++ {
+		for _, id := range ix.lengths[length] {
+			term := ix.terms[id]
+			if strings.Contains(term, word) {
+				continue
+			}
+			if similarity := Similarity(word, term); similarity >= threshold {
+				add(term, similarity)
+			}
+		}
+	}
+
+	return matches
+}
+
+// Similarity returns 1 when term contains word, and otherwise one minus the
+// Levenshtein distance between them divided by the longer length
+func Similarity(word, term string) float64 {
+	if strings.Contains(term, word) {
+		return 1
+	}
+	a, b := []rune(word), []rune(term)
+	longest := max(len(a), len(b))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(a, b))/float64(longest)
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
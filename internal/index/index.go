@@ -0,0 +1,602 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+)
+
+// formatVersion is bumped whenever the on-disk layout changes, forcing a rebuild
+const formatVersion = 1
+
+// indexDir and indexFile locate the index inside a context directory
+const (
+	indexDir  = ".index"
+	indexFile = "search.gob"
+)
+
+// gramSize is the longest n-gram, in runes, used to find the terms containing
+// a query term
+const gramSize = 3
+
+// numFields is the number of snippet fields tracked per posting
+const numFields = 3
+
+// Index is an inverted index over every llms.txt document in a context directory
+type Index struct {
+	Version   int
+	Documents []Document
+	// Postings maps a term to the snippets containing it
+	Postings map[string][]Posting
+
+	// terms is the sorted vocabulary, rebuilt after loading with grams,
+	// listing the terms containing each n-gram, and lengths, listing the
+	// terms of each length in runes
+	terms   []string
+	grams   map[string][]int32
+	lengths [][]int32
+	// snippetCount and avgFieldLength feed BM25 scoring, rebuilt after loading
+	snippetCount   int
+	avgFieldLength [numFields]float64
+	// generation counts the changes made to a cached index
+	generation int
+	// dir is the context directory the index was loaded from
+	dir string
+}
+
+// Document describes one indexed llms.txt file
+type Document struct {
+	Repo string
+	// Path is relative to the context directory
+	Path    string
+	ModTime int64
+	Size    int64
+
+	Snippets []Snippet
+
+	// root is the context directory holding the document, set when loading
+	root string
+}
+
+// File returns the path of the document's llms.txt file
+func (d *Document) File() string {
+	return filepath.Join(d.root, d.Path)
+}
+
+// Root returns the context directory holding the document
+func (d *Document) Root() string {
+	return d.root
+}
+
+// Snippet holds the parts of a snippet needed to answer title queries without reading the file
+type Snippet struct {
+	Title           string
+	Description     string
+	TitleLine       int
+	DescriptionLine int
+	StartLine       int
+	EndLine         int
+	Languages       []string
+	// FieldLengths is the number of terms in the title, description and code
+	FieldLengths [numFields]int
+}
+
+// Posting records how often a term occurs in each field of a snippet
+type Posting struct {
+	Doc     int
+	Snippet int
+	Freq    [numFields]int
+}
+
+// Ref identifies a snippet within the index
+type Ref struct {
+	Doc     int
+	Snippet int
+}
+
+var (
+	cacheMu sync.Mutex
+	cache   = make(map[string]*Index)
+)
+
+// Load returns the index for contextDir, reading it from disk when it is not
+// cached in memory and re-indexing any documents that were added, changed or
+// removed since it was written. A missing or unreadable index is rebuilt.
+// A returned index is never modified afterwards, so searches may keep using
+// it while later calls index changed documents into a copy.
+func Load(contextDir string) (*Index, error) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+
+	key, err := filepath.Abs(contextDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", contextDir, err)
+	}
+
+	files, err := scan(contextDir)
+	if err != nil {
+		return nil, err
+	}
+
+	ix, ok := cache[key]
+	switch {
+	case ok && ix.current(contextDir, files):
+		return ix, nil
+	case ok:
+		ix = ix.clone()
+	default:
+		ix, err = read(contextDir)
+		if err != nil {
+			log.Printf("Rebuilding search index for %s: %v", contextDir, err)
+			ix = &Index{Version: formatVersion, Postings: make(map[string][]Posting)}
+		}
+	}
+
+	if ix.sync(contextDir, files) {
+		if err := ix.write(contextDir); err != nil {
+			log.Printf("Failed to write search index for %s: %v", contextDir, err)
+		}
+		ix.generation++
+	}
+	ix.dir = contextDir
+	for i := range ix.Documents {
+		ix.Documents[i].root = contextDir
+	}
+
+	cache[key] = ix
+	return ix, nil
+}
+
+// current reports whether the index was loaded from contextDir and matches
+// the files found there
+func (ix *Index) current(contextDir string, files map[string]storedFile) bool {
+	if ix.dir != contextDir || len(ix.Documents) != len(files) {
+		return false
+	}
+	for _, doc := range ix.Documents {
+		file, ok := files[doc.Path]
+		if !ok || file.info.ModTime().UnixNano() != doc.ModTime || file.info.Size() != doc.Size {
+			return false
+		}
+	}
+	return true
+}
+
+// clone copies the index deeply enough for sync to change the copy without
+// affecting searches still using the original
+func (ix *Index) clone() *Index {
+	c := *ix
+	c.Documents = append([]Document(nil), ix.Documents...)
+	c.Postings = make(map[string][]Posting, len(ix.Postings))
+	for term, postings := range ix.Postings {
+		c.Postings[term] = append([]Posting(nil), postings...)
+	}
+	return &c
+}
+
+// Update brings the index for contextDir up to date after a document was written
+func Update(contextDir string) error {
+	_, err := Load(contextDir)
+	return err
+}
+
+// read decodes the index file of contextDir
+func read(contextDir string) (*Index, error) {
+	f, err := os.Open(filepath.Join(contextDir, indexDir, indexFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ix Index
+	if err := gob.NewDecoder(f).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("failed to decode index: %v", err)
+	}
+	if ix.Version != formatVersion {
+		return nil, fmt.Errorf("index format %d is outdated", ix.Version)
+	}
+	if ix.Postings == nil {
+		ix.Postings = make(map[string][]Posting)
+	}
+	ix.buildTerms()
+	ix.buildStats()
+
+	return &ix, nil
+}
+
+// write stores the index in contextDir, replacing the previous file atomically
+func (ix *Index) write(contextDir string) error {
+	dir := filepath.Join(contextDir, indexDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", dir, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, indexFile+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary index file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode index: %v", err)
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to set index permissions: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write index: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, indexFile))
+}
+
+// storedFile is an llms.txt file found while scanning a context directory
+type storedFile struct {
+	repo string
+	path string
+	info os.FileInfo
+}
+
+// scan lists the llms.txt files of contextDir keyed by their relative path
+func scan(contextDir string) (map[string]storedFile, error) {
+	files := make(map[string]storedFile)
+
+	if _, err := os.Stat(contextDir); os.IsNotExist(err) {
+		return files, nil
+	}
+
+	err := filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && (info.Name() == indexDir || info.Name() == store.SnapshotDir) {
+			return filepath.SkipDir
+		}
+		// Symbolic links could lead outside the store, so only regular files count
+		if !strings.HasPrefix(info.Name(), "llms.") || !info.Mode().IsRegular() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(contextDir, path)
+		if err != nil {
+			return err
+		}
+		// Only "owner/repo/llms.txt" and its "llms.<variant>.txt" variants
+		// are stored documents; deeper files belong to other stores nested
+		// in this one
+		repo, ok := store.RepoFromPath(relPath)
+		if !ok {
+			return nil
+		}
+
+		files[relPath] = storedFile{
+			repo: repo.String(),
+			path: relPath,
+			info: info,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %v", contextDir, err)
+	}
+
+	return files, nil
+}
+
+// sync re-indexes documents whose files changed and drops documents whose
+// files disappeared, consuming files. It reports whether the index was modified.
+func (ix *Index) sync(contextDir string, files map[string]storedFile) bool {
+	changed := false
+	for i := len(ix.Documents) - 1; i >= 0; i-- {
+		doc := ix.Documents[i]
+		file, ok := files[doc.Path]
+		if ok && file.info.ModTime().UnixNano() == doc.ModTime && file.info.Size() == doc.Size {
+			delete(files, doc.Path)
+			continue
+		}
+		ix.remove(i)
+		changed = true
+	}
+
+	// Add new and modified files in path order so document ids are stable
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		file := files[path]
+		parsed, err := snippet.ParseFile(filepath.Join(contextDir, path))
+		if err != nil {
+			log.Printf("Failed to index %s: %v", path, err)
+			continue
+		}
+		ix.add(file, parsed)
+		changed = true
+	}
+
+	if changed {
+		ix.sortDocuments()
+		ix.buildTerms()
+		ix.buildStats()
+	}
+
+	return changed
+}
+
+// add indexes a parsed document
+func (ix *Index) add(file storedFile, parsed *snippet.Document) {
+	docID := len(ix.Documents)
+	doc := Document{
+		Repo:    file.repo,
+		Path:    file.path,
+		ModTime: file.info.ModTime().UnixNano(),
+		Size:    file.info.Size(),
+	}
+
+	for snippetID, snip := range parsed.Snippets {
+		entry := Snippet{
+			Title:           snip.Title,
+			Description:     snip.Description,
+			TitleLine:       snip.TitleLine,
+			DescriptionLine: snip.DescriptionLine,
+			StartLine:       snip.StartLine,
+			EndLine:         snip.EndLine,
+			Languages:       snip.Languages(),
+		}
+
+		freqs := make(map[string]*[numFields]int)
+		for field := snippet.FieldTitle; field <= snippet.FieldCode; field++ {
+			for _, term := range Tokenize(snip.Text(field)) {
+				if freqs[term] == nil {
+					freqs[term] = new([numFields]int)
+				}
+				freqs[term][field]++
+				entry.FieldLengths[field]++
+			}
+		}
+		for term, freq := range freqs {
+			ix.Postings[term] = append(ix.Postings[term], Posting{Doc: docID, Snippet: snippetID, Freq: *freq})
+		}
+
+		doc.Snippets = append(doc.Snippets, entry)
+	}
+
+	ix.Documents = append(ix.Documents, doc)
+}
+
+// remove drops a document and renumbers the documents after it
+func (ix *Index) remove(docID int) {
+	for term, postings := range ix.Postings {
+		kept := postings[:0]
+		for _, p := range postings {
+			switch {
+			case p.Doc == docID:
+				continue
+			case p.Doc > docID:
+				p.Doc--
+			}
+			kept = append(kept, p)
+		}
+		if len(kept) == 0 {
+			delete(ix.Postings, term)
+		} else {
+			ix.Postings[term] = kept
+		}
+	}
+	ix.Documents = append(ix.Documents[:docID], ix.Documents[docID+1:]...)
+}
+
+// sortDocuments orders documents by path and renumbers the postings to match
+func (ix *Index) sortDocuments() {
+	order := make([]int, len(ix.Documents))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return ix.Documents[order[a]].Path < ix.Documents[order[b]].Path
+	})
+
+	newID := make([]int, len(order))
+	documents := make([]Document, len(order))
+	for i, old := range order {
+		newID[old] = i
+		documents[i] = ix.Documents[old]
+	}
+	ix.Documents = documents
+
+	for _, postings := range ix.Postings {
+		for i := range postings {
+			postings[i].Doc = newID[postings[i].Doc]
+		}
+		sort.Slice(postings, func(a, b int) bool {
+			if postings[a].Doc != postings[b].Doc {
+				return postings[a].Doc < postings[b].Doc
+			}
+			return postings[a].Snippet < postings[b].Snippet
+		})
+	}
+}
+
+// buildTerms rebuilds the sorted vocabulary and the tables locating terms by
+// n-gram and by length. New tables are made so that copies of the index
+// sharing the old ones are unaffected.
+func (ix *Index) buildTerms() {
+	ix.terms = make([]string, 0, len(ix.Postings))
+	for term := range ix.Postings {
+		ix.terms = append(ix.terms, term)
+	}
+	sort.Strings(ix.terms)
+
+	ix.grams = make(map[string][]int32)
+	ix.lengths = nil
+	for id, term := range ix.terms {
+		runes := []rune(term)
+		for n := 2; n <= gramSize; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				gram := string(runes[i : i+n])
+				// A term repeating a gram is listed once
+				if ids := ix.grams[gram]; len(ids) == 0 || ids[len(ids)-1] != int32(id) {
+					ix.grams[gram] = append(ids, int32(id))
+				}
+			}
+		}
+		for len(ix.lengths) <= len(runes) {
+			ix.lengths = append(ix.lengths, nil)
+		}
+		ix.lengths[len(runes)] = append(ix.lengths[len(runes)], int32(id))
+	}
+}
+
+// containing returns the terms of the vocabulary that contain text. Terms
+// holding every n-gram of text are looked up and then checked, starting from
+// the n-gram found in the fewest terms.
+func (ix *Index) containing(text string) []string {
+	runes := []rune(text)
+	var candidates []int32
+	switch {
+	case len(runes) == 0:
+		return nil
+	case len(runes) == 1:
+		// Most terms contain a given letter, so checking them all costs
+		// about as much as listing the matches
+		var terms []string
+		for _, term := range ix.terms {
+			if strings.Contains(term, text) {
+				terms = append(terms, term)
+			}
+		}
+		return terms
+	case len(runes) <= gramSize:
+		candidates = ix.grams[text]
+	default:
+		for i := 0; i+gramSize <= len(runes); i++ {
+			ids, ok := ix.grams[string(runes[i:i+gramSize])]
+			if !ok {
+				return nil
+			}
+			if candidates == nil || len(ids) < len(candidates) {
+				candidates = ids
+			}
+		}
+	}
+
+	terms := make([]string, 0, len(candidates))
+	for _, id := range candidates {
+		if term := ix.terms[id]; strings.Contains(term, text) {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// Tokenize splits text into lowercase terms made of letters and digits
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Snippet returns the indexed snippet for ref
+func (ix *Index) Snippet(ref Ref) *Snippet {
+	return &ix.Documents[ref.Doc].Snippets[ref.Snippet]
+}
+
+// All returns every snippet in document and line order, optionally limited
+// to one repository
+func (ix *Index) All(repoFilter string) []Ref {
+	var refs []Ref
+	for docID, doc := range ix.Documents {
+		if repoFilter != "" && doc.Repo != repoFilter {
+			continue
+		}
+		for snippetID := range doc.Snippets {
+			refs = append(refs, Ref{Doc: docID, Snippet: snippetID})
+		}
+	}
+	return refs
+}
+
+// Fingerprint identifies the stored documents the index was built from. It
+// changes when a document is added, removed or rewritten.
+func (ix *Index) Fingerprint() string {
+	h := sha256.New()
+	for _, doc := range ix.Documents {
+		fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d\x00", doc.Root(), doc.Path, doc.ModTime, doc.Size)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:6])
+}
+
+// Lookup returns the snippets in which every term of text occurs inside some
+// indexed term of the selected fields. This is a superset of the snippets
+// containing text as a substring of those fields, so callers verify the text
+// itself. ok is false when text has no letters or digits to look up.
+func (ix *Index) Lookup(text string, fields snippet.FieldMask) (refs map[Ref]bool, ok bool) {
+	queryTerms := Tokenize(text)
+	if len(queryTerms) == 0 {
+		return nil, false
+	}
+
+	for _, queryTerm := range queryTerms {
+		current := make(map[Ref]bool)
+		for _, term := range ix.containing(queryTerm) {
+			for _, p := range ix.Postings[term] {
+				if !p.inFields(fields) {
+					continue
+				}
+				ref := Ref{Doc: p.Doc, Snippet: p.Snippet}
+				if refs == nil || refs[ref] {
+					current[ref] = true
+				}
+			}
+		}
+		refs = current
+		if len(refs) == 0 {
+			break
+		}
+	}
+
+	return refs, true
+}
+
+// Language returns the snippets with an example in the given language,
+// compared after snippet.NormalizeLanguage
+func (ix *Index) Language(language string) map[Ref]bool {
+	language = snippet.NormalizeLanguage(language)
+	refs := make(map[Ref]bool)
+	for docID, doc := range ix.Documents {
+		for snippetID, snip := range doc.Snippets {
+			for _, snippetLanguage := range snip.Languages {
+				if snippet.NormalizeLanguage(snippetLanguage) == language {
+					refs[Ref{Doc: docID, Snippet: snippetID}] = true
+					break
+				}
+			}
+		}
+	}
+	return refs
+}
+
+// inFields reports whether the term occurs in any of the selected fields
+func (p Posting) inFields(fields snippet.FieldMask) bool {
+	for field := 0; field < numFields; field++ {
+		if fields&(1<<field) != 0 && p.Freq[field] > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"docs4context-com/internal/snippet"
+)
+
+// writeDocument stores an llms.txt document for repo under root
+func writeDocument(t *testing.T, root, repo, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(repo), "llms.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadKeepsReturnedIndexUnchanged(t *testing.T) {
+	root := t.TempDir()
+	writeDocument(t, root, "a/b", "TITLE: Routing\nDESCRIPTION: Routes requests\nSOURCE: s1\n")
+
+	before, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := Load(root); err != nil || again != before {
+		t.Fatalf("Load of an unchanged store = %p, %v, want the cached index %p", again, err, before)
+	}
+
+	// A second document and a rewrite of the first, with a later modtime so
+	// the change is seen on file systems with coarse timestamps
+	writeDocument(t, root, "a/b", "TITLE: Caching\nDESCRIPTION: Caches responses\nSOURCE: s2\n")
+	writeDocument(t, root, "c/d", "TITLE: Routing\nDESCRIPTION: Routes again\nSOURCE: s3\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "a", "b", "llms.txt"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after == before {
+		t.Fatal("Load modified the index it returned before")
+	}
+	if len(before.Documents) != 1 || before.Documents[0].Snippets[0].Title != "Routing" {
+		t.Errorf("earlier index documents = %+v, want only the original a/b", before.Documents)
+	}
+	if refs, _ := before.Lookup("routing", snippet.MaskTitle); len(refs) != 1 {
+		t.Errorf("earlier index matches routing in %d snippets, want 1", len(refs))
+	}
+	if refs, _ := after.Lookup("routing", snippet.MaskTitle); len(refs) != 1 || !refs[Ref{Doc: 1, Snippet: 0}] {
+		t.Errorf("updated index matches routing in %v, want only c/d", refs)
+	}
+	if refs, _ := after.Lookup("caching", snippet.MaskTitle); len(refs) != 1 {
+		t.Errorf("updated index matches caching in %d snippets, want 1", len(refs))
+	}
+}
+
+func TestLookupMatchesSubstringsOfTerms(t *testing.T) {
+	root := t.TempDir()
+	writeDocument(t, root, "a/b", `TITLE: Panic recovery middleware
+DESCRIPTION: Recovers from panics in handlers
+SOURCE: s1
+----------------------------------------
+TITLE: Routing
+DESCRIPTION: Groups routes under a prefix
+SOURCE: s2
+----------------------------------------
+TITLE: Übergröße
+DESCRIPTION: Grüße aus Köln
+SOURCE: s3
+`)
+	ix, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every lookup agrees with checking the whole vocabulary
+	for _, text := range []string{"recov", "recovery", "ecover", "ware", "co", "r", "x", "group", "routes", "größe", "öl", "grüße köln", "zzz", "recov zzz"} {
+		got, ok := ix.Lookup(text, snippet.MaskTitle|snippet.MaskDescription)
+		if !ok {
+			t.Fatalf("Lookup(%q) found no terms to look up", text)
+		}
+		want := make(map[Ref]bool)
+		for docID, doc := range ix.Documents {
+			for snippetID, snip := range doc.Snippets {
+				terms := Tokenize(snip.Title + " " + snip.Description)
+				all := true
+				for _, queryTerm := range Tokenize(text) {
+					found := false
+					for _, term := range terms {
+						found = found || strings.Contains(term, queryTerm)
+					}
+					all = all && found
+				}
+				if all {
+					want[Ref{Doc: docID, Snippet: snippetID}] = true
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, want) && len(got)+len(want) > 0 {
+			t.Errorf("Lookup(%q) = %v, want %v", text, got, want)
+		}
+	}
+
+	if _, ok := ix.Lookup("--", snippet.MaskTitle); ok {
+		t.Error("Lookup of text without letters or digits reported terms")
+	}
+}
+
+func TestFuzzyLookupMatchesVocabularyScan(t *testing.T) {
+	root := t.TempDir()
+	writeDocument(t, root, "a/b", `TITLE: Panic recovery middleware
+SOURCE: s1
+----------------------------------------
+TITLE: Recover handlers
+SOURCE: s2
+----------------------------------------
+TITLE: Routing groups
+SOURCE: s3
+`)
+	ix, err := Load(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, word := range []string{"recovry", "middlewear", "recov", "rooting", "groups", "a", "zzzzzz"} {
+		for _, threshold := range []float64{0.5, 0.75, 1} {
+			want := make(map[Ref]FuzzyMatch)
+			for _, term := range ix.terms {
+				similarity := Similarity(word, term)
+				if similarity < threshold {
+					continue
+				}
+				for _, p := range ix.Postings[term] {
+					ref := Ref{Doc: p.Doc, Snippet: p.Snippet}
+					if best, ok := want[ref]; !ok || similarity > best.Similarity {
+						want[ref] = FuzzyMatch{Term: term, Similarity: similarity}
+					}
+				}
+			}
+			got := ix.FuzzyLookup(word, snippet.MaskTitle, threshold)
+			if len(got) != len(want) {
+				t.Errorf("FuzzyLookup(%q, %g) = %v, want %v", word, threshold, got, want)
+				continue
+			}
+			for ref, match := range want {
+				if got[ref].Similarity != match.Similarity {
+					t.Errorf("FuzzyLookup(%q, %g) similarity of %v = %v, want %v", word, threshold, ref, got[ref], match)
+				}
+			}
+		}
+	}
+
+	if matches := ix.FuzzyLookup("recovry", snippet.MaskTitle, 0.75); matches[Ref{Doc: 0, Snippet: 0}].Term != "recovery" {
+		t.Errorf("FuzzyLookup(recovry) = %v, want recovery in the first snippet", matches)
+	}
+}
@@ -0,0 +1,95 @@
+package index
+
+import (
+	"strings"
+	"sync"
+)
+
+// merged is a cached merge of several indexes
+type merged struct {
+	sources     []*Index
+	generations []int
+	ix          *Index
+}
+
+var (
+	mergedMu    sync.Mutex
+	mergedCache = make(map[string]*merged)
+)
+
+// LoadAll loads the indexes of several context directories and merges them.
+// Documents are ordered by directory, then by path. A repository stored in
+// more than one directory is taken from the first directory holding it.
+func LoadAll(contextDirs []string) (*Index, error) {
+	sources := make([]*Index, len(contextDirs))
+	for i, contextDir := range contextDirs {
+		ix, err := Load(contextDir)
+		if err != nil {
+			return nil, err
+		}
+		sources[i] = ix
+	}
+	if len(sources) == 1 {
+		return sources[0], nil
+	}
+
+	mergedMu.Lock()
+	defer mergedMu.Unlock()
+
+	key := strings.Join(contextDirs, "\x00")
+	if cached, ok := mergedCache[key]; ok && cached.current(sources) {
+		return cached.ix, nil
+	}
+
+	entry := &merged{sources: sources, ix: merge(sources)}
+	for _, source := range sources {
+		entry.generations = append(entry.generations, source.generation)
+	}
+	mergedCache[key] = entry
+
+	return entry.ix, nil
+}
+
+// current reports whether the merge was built from the given indexes as they are now
+func (m *merged) current(sources []*Index) bool {
+	for i, source := range sources {
+		if m.sources[i] != source || m.generations[i] != source.generation {
+			return false
+		}
+	}
+	return true
+}
+
+// merge combines indexes into a new one, renumbering documents and skipping
+// repositories already present in an earlier index
+func merge(sources []*Index) *Index {
+	ix := &Index{Version: formatVersion, Postings: make(map[string][]Posting)}
+	seen := make(map[string]bool)
+
+	for _, source := range sources {
+		newID := make(map[int]int)
+		for docID, doc := range source.Documents {
+			if seen[doc.Repo] {
+				continue
+			}
+			newID[docID] = len(ix.Documents)
+			ix.Documents = append(ix.Documents, doc)
+		}
+		for _, doc := range source.Documents {
+			seen[doc.Repo] = true
+		}
+
+		for term, postings := range source.Postings {
+			for _, p := range postings {
+				if id, ok := newID[p.Doc]; ok {
+					p.Doc = id
+					ix.Postings[term] = append(ix.Postings[term], p)
+				}
+			}
+		}
+	}
+
+	ix.buildTerms()
+	ix.buildStats()
+	return ix
+}
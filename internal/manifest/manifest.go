@@ -0,0 +1,98 @@
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/store"
+)
+
+// DefaultFile is the manifest looked for in the working directory
+const DefaultFile = "docs4context.json"
+
+// Manifest lists the documents a project depends on. It is committed with
+// the project so that every teammate's agent sees the same documents.
+type Manifest struct {
+	// MaxAgeDays makes documents older than this many days stale; 0 keeps
+	// documents until their entry changes
+	MaxAgeDays int `json:"max_age_days,omitempty"`
+	// Documents are the documents the project store should hold
+	Documents []Entry `json:"documents"`
+}
+
+// Entry is one document listed in a manifest
+type Entry struct {
+	// Repo is a GitHub URL or "owner/repo"; with URL set it names the
+	// repository the document is stored under
+	Repo string `json:"repo,omitempty"`
+	// URL is the address of an llms.txt document published by a project
+	URL string `json:"url,omitempty"`
+	// Version selects the documentation of a library version
+	Version string `json:"version,omitempty"`
+	// Topic and MaxTokens select part of the document, stored as a variant
+	Topic     string `json:"topic,omitempty"`
+	MaxTokens int    `json:"max_tokens,omitempty"`
+	// Source names the source to download from; empty selects the default
+	Source string `json:"source,omitempty"`
+}
+
+// Load reads and validates the manifest at path
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %v", path, err)
+	}
+
+	var m Manifest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %v", path, err)
+	}
+	if m.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("invalid manifest %s: max_age_days must not be negative", path)
+	}
+
+	seen := make(map[string]int)
+	for i, entry := range m.Documents {
+		if strings.TrimSpace(entry.Repo) == "" && strings.TrimSpace(entry.URL) == "" {
+			return nil, fmt.Errorf("invalid manifest %s, document %d: 'repo' or 'url' is required", path, i+1)
+		}
+		repo, err := entry.repo()
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s, document %d: %v", path, i+1, err)
+		}
+		if first, ok := seen[repo.String()]; ok {
+			return nil, fmt.Errorf("invalid manifest %s: documents %d and %d both store %s", path, first, i+1, repo)
+		}
+		seen[repo.String()] = i + 1
+	}
+
+	return &m, nil
+}
+
+// Request returns the request saving the document of the entry into outputDir
+func (e Entry) Request(outputDir string) savecontext.Request {
+	req := savecontext.Request{
+		Target:    strings.TrimSpace(e.Repo),
+		Source:    e.Source,
+		Version:   e.Version,
+		Topic:     e.Topic,
+		MaxTokens: e.MaxTokens,
+		OutputDir: outputDir,
+	}
+	if e.URL != "" {
+		req.Target = strings.TrimSpace(e.URL)
+		req.Repo = strings.TrimSpace(e.Repo)
+	}
+	return req
+}
+
+// repo returns the repository, with its variant, the entry is stored under
+func (e Entry) repo() (store.Repo, error) {
+	return savecontext.TargetRepo(e.Request(""))
+}
@@ -0,0 +1,291 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Actions a sync takes on a document
+const (
+	ActionDownload = "download"
+	ActionRefresh  = "refresh"
+	ActionRemove   = "remove"
+	ActionKeep     = "keep"
+)
+
+// Change is what a sync did, or would do, with one document
+type Change struct {
+	Repo   string `json:"repo"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+	Path   string `json:"path"`
+	Error  string `json:"error,omitempty"`
+
+	// request saves the document for downloads and refreshes
+	request savecontext.Request
+}
+
+// syncResponse is the JSON form of sync_context_documents
+type syncResponse struct {
+	Manifest string   `json:"manifest"`
+	Store    string   `json:"store"`
+	DryRun   bool     `json:"dry_run"`
+	Changes  []Change `json:"changes"`
+}
+
+// AddSyncTool adds the tool syncing the project store with a manifest
+func AddSyncTool(s *server.MCPServer) {
+	syncTool := mcp.NewTool("sync_context_documents",
+		mcp.WithDescription("Sync the project store with the project's docs4context.json manifest: download missing documents, refresh stale ones and remove the ones no longer listed"),
+		mcp.WithString("manifest",
+			mcp.Description("Path to the manifest (default: docs4context.json in the working directory)"),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only report what would change, without downloading or removing anything"),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of documents to download at a time (default %d, at most %d)", savecontext.DefaultConcurrency, savecontext.MaxConcurrency)),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(syncTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("SYNC_CONTEXT_DOCUMENTS tool called")
+
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("SYNC_CONTEXT_DOCUMENTS tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		concurrency := request.GetInt("concurrency", savecontext.DefaultConcurrency)
+		if concurrency < 1 || concurrency > savecontext.MaxConcurrency {
+			log.Printf("SYNC_CONTEXT_DOCUMENTS tool error - invalid parameter 'concurrency': %d", concurrency)
+			return mcp.NewToolResultError(fmt.Sprintf("concurrency must be between 1 and %d", savecontext.MaxConcurrency)), nil
+		}
+
+		path := request.GetString("manifest", DefaultFile)
+		m, err := Load(path)
+		if err != nil {
+			log.Printf("SYNC_CONTEXT_DOCUMENTS tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		dryRun := request.GetBool("dry_run", false)
+		changes, err := Sync(ctx, m, store.Root(), dryRun, concurrency)
+		if err != nil {
+			log.Printf("SYNC_CONTEXT_DOCUMENTS tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(syncResponse{Manifest: path, Store: store.Root(), DryRun: dryRun, Changes: changes})
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(FormatChanges(path, changes, dryRun)), nil
+	})
+}
+
+// Plan compares the documents in root with the manifest. Listed documents
+// that are missing are downloaded; those whose version differs from the
+// manifest, or that are older than MaxAgeDays, are refreshed; documents in
+// root that are not listed are removed.
+func Plan(m *Manifest, root string) ([]Change, error) {
+	var changes []Change
+	listed := make(map[string]bool)
+
+	for _, entry := range m.Documents {
+		repo, err := entry.repo()
+		if err != nil {
+			return nil, err
+		}
+		listed[repo.String()] = true
+
+		change := Change{Repo: repo.String(), Path: repo.File(root), request: entry.Request(root)}
+		change.Action, change.Reason = staleness(change.Path, entry, m.MaxAgeDays)
+		changes = append(changes, change)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(root, "*", "*", "llms*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	for _, match := range matches {
+		rel, err := filepath.Rel(root, match)
+		if err != nil {
+			return nil, err
+		}
+		repo, ok := store.RepoFromPath(rel)
+		if !ok || listed[repo.String()] {
+			continue
+		}
+		changes = append(changes, Change{Repo: repo.String(), Action: ActionRemove, Reason: "not in the manifest", Path: match})
+	}
+
+	return changes, nil
+}
+
+// staleness decides whether the stored document at path is up to date
+func staleness(path string, entry Entry, maxAgeDays int) (action, reason string) {
+	info, err := os.Lstat(path)
+	if err != nil || !info.Mode().IsRegular() {
+		return ActionDownload, "not downloaded yet"
+	}
+
+	doc, err := snippet.ParseFile(path)
+	if err != nil {
+		return ActionRefresh, "unreadable"
+	}
+	if stored := doc.Metadata.Fields["VERSION"]; stored != entry.Version {
+		switch {
+		case stored == "":
+			return ActionRefresh, fmt.Sprintf("version %s requested", entry.Version)
+		case entry.Version == "":
+			return ActionRefresh, fmt.Sprintf("version %s is pinned but the manifest asks for the latest", stored)
+		default:
+			return ActionRefresh, fmt.Sprintf("version %s -> %s", stored, entry.Version)
+		}
+	}
+	if maxAgeDays > 0 {
+		created, err := time.Parse(time.RFC3339, doc.Metadata.DateCreated)
+		if err != nil {
+			return ActionRefresh, "unknown download date"
+		}
+		if age := time.Since(created); age > time.Duration(maxAgeDays)*24*time.Hour {
+			return ActionRefresh, fmt.Sprintf("downloaded %d days ago", int(age.Hours()/24))
+		}
+	}
+	return ActionKeep, ""
+}
+
+// Sync brings root in line with the manifest: it downloads and refreshes
+// documents with at most concurrency downloads at a time, then removes the
+// unlisted ones. With dryRun it only returns the planned changes.
+func Sync(ctx context.Context, m *Manifest, root string, dryRun bool, concurrency int) ([]Change, error) {
+	changes, err := Plan(m, root)
+	if err != nil || dryRun {
+		return changes, err
+	}
+
+	var requests []savecontext.Request
+	var pending []int
+	for i, change := range changes {
+		if change.Action == ActionDownload || change.Action == ActionRefresh {
+			requests = append(requests, change.request)
+			pending = append(pending, i)
+		}
+	}
+	if len(requests) > 0 {
+		for j, result := range savecontext.Batch(ctx, requests, concurrency) {
+			if !result.OK {
+				changes[pending[j]].Error = result.Error
+			}
+		}
+	}
+
+	removed := false
+	for i, change := range changes {
+		if change.Action != ActionRemove {
+			continue
+		}
+		if err := removeDocument(root, change.Path); err != nil {
+			changes[i].Error = err.Error()
+			continue
+		}
+		removed = true
+	}
+	if removed {
+		if err := index.Update(root); err != nil {
+			log.Printf("Warning - failed to update search index of %s: %v", root, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// removeDocument deletes a stored document, and its repository and owner
+// directories when they are left empty
+func removeDocument(root, path string) error {
+	if err := store.CheckDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%s is not a regular file", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove %s: %v", path, err)
+	}
+	log.Printf("Removed %s", path)
+
+	// Removing a directory fails while it still holds files, which is fine
+	repoDir := filepath.Dir(path)
+	if os.Remove(repoDir) == nil {
+		os.Remove(filepath.Dir(repoDir))
+	}
+	return nil
+}
+
+// FormatChanges renders the result of a sync as text
+func FormatChanges(path string, changes []Change, dryRun bool) string {
+	counts := make(map[string]int)
+	failed := 0
+	for _, change := range changes {
+		if change.Error != "" {
+			failed++
+			continue
+		}
+		counts[change.Action]++
+	}
+
+	verbs := map[string]string{ActionDownload: "downloaded", ActionRefresh: "refreshed", ActionRemove: "removed", ActionKeep: "up to date"}
+	title := fmt.Sprintf("=== Sync of %s: %d downloaded, %d refreshed, %d removed, %d up to date, %d failed ===",
+		path, counts[ActionDownload], counts[ActionRefresh], counts[ActionRemove], counts[ActionKeep], failed)
+	if dryRun {
+		verbs = map[string]string{ActionDownload: "would download", ActionRefresh: "would refresh", ActionRemove: "would remove", ActionKeep: "up to date"}
+		title = fmt.Sprintf("=== Sync plan for %s (dry run): %d to download, %d to refresh, %d to remove, %d up to date ===",
+			path, counts[ActionDownload], counts[ActionRefresh], counts[ActionRemove], counts[ActionKeep])
+	}
+
+	markers := map[string]string{ActionDownload: "+", ActionRefresh: "~", ActionRemove: "-", ActionKeep: "="}
+	lines := []string{title, ""}
+	for _, change := range changes {
+		switch {
+		case change.Error != "":
+			lines = append(lines, fmt.Sprintf("! %s: failed to %s: %s", change.Repo, change.Action, change.Error))
+		case change.Reason != "":
+			lines = append(lines, fmt.Sprintf("%s %s: %s (%s)", markers[change.Action], change.Repo, verbs[change.Action], change.Reason))
+		default:
+			lines = append(lines, fmt.Sprintf("%s %s: %s", markers[change.Action], change.Repo, verbs[change.Action]))
+		}
+	}
+	if len(changes) == 0 {
+		lines = append(lines, "The manifest lists no documents and the project store is empty.")
+	}
+	return strings.Join(lines, "\n")
+}
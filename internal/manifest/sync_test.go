@@ -0,0 +1,154 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"docs4context-com/internal/store"
+)
+
+// newStore configures a temporary project store and returns its root
+func newStore(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "llm-context")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Configure(store.Options{Root: root, GlobalRoot: filepath.Join(dir, "global"), ConfigPath: configPath}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Configure(store.Options{ConfigPath: configPath})
+	})
+	return root
+}
+
+// writeFile writes a file below root, with a metadata header holding the
+// given fields when fields is not nil
+func writeFile(t *testing.T, root, rel string, fields map[string]string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(rel))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := ""
+	if fields != nil {
+		content = "# METADATA\n"
+		for key, value := range fields {
+			content += fmt.Sprintf("# %s: %s\n", key, value)
+		}
+		content += "#\n"
+	}
+	content += "TITLE: T\nDESCRIPTION: d\nSOURCE: s\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlan(t *testing.T) {
+	root := newStore(t)
+	now := time.Now().UTC().Format(time.RFC3339)
+	old := time.Now().Add(-30 * 24 * time.Hour).UTC().Format(time.RFC3339)
+
+	writeFile(t, root, "kept/latest/llms.txt", map[string]string{"DATE_CREATED": now})
+	writeFile(t, root, "kept/pinned/llms.txt", map[string]string{"DATE_CREATED": now, "VERSION": "v1.0.0"})
+	writeFile(t, root, "bump/version/llms.txt", map[string]string{"DATE_CREATED": now, "VERSION": "v1.0.0"})
+	writeFile(t, root, "pin/version/llms.txt", map[string]string{"DATE_CREATED": now})
+	writeFile(t, root, "unpin/version/llms.txt", map[string]string{"DATE_CREATED": now, "VERSION": "v1.0.0"})
+	writeFile(t, root, "too/old/llms.txt", map[string]string{"DATE_CREATED": old})
+	writeFile(t, root, "no/date/llms.txt", map[string]string{})
+	writeFile(t, root, "from/url/llms.txt", map[string]string{"DATE_CREATED": now})
+	// Documents not in the manifest, including a variant of a listed repository
+	writeFile(t, root, "unlisted/repo/llms.txt", map[string]string{"DATE_CREATED": now})
+	writeFile(t, root, "kept/latest/llms.routing.txt", map[string]string{"DATE_CREATED": now})
+	// Files that are not stored documents are left alone
+	writeFile(t, root, "other/repo/notes.txt", nil)
+	writeFile(t, root, "deeper/nested/store/llms.txt", nil)
+	writeFile(t, root, "llms.txt", nil)
+	writeFile(t, root, ".snapshots/20250101T000000Z/unlisted/repo/llms.txt", nil)
+
+	m := &Manifest{
+		MaxAgeDays: 7,
+		Documents: []Entry{
+			{Repo: "kept/latest"},
+			{Repo: "https://github.com/kept/pinned", Version: "v1.0.0"},
+			{Repo: "bump/version", Version: "v2.0.0"},
+			{Repo: "pin/version", Version: "v1.0.0"},
+			{Repo: "unpin/version"},
+			{Repo: "too/old"},
+			{Repo: "no/date"},
+			{Repo: "missing/repo"},
+			{Repo: "from/url", URL: "https://example.com/llms.txt"},
+		},
+	}
+	changes, err := Plan(m, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{Repo: "kept/latest", Action: ActionKeep},
+		{Repo: "kept/pinned", Action: ActionKeep},
+		{Repo: "bump/version", Action: ActionRefresh, Reason: "version v1.0.0 -> v2.0.0"},
+		{Repo: "pin/version", Action: ActionRefresh, Reason: "version v1.0.0 requested"},
+		{Repo: "unpin/version", Action: ActionRefresh, Reason: "version v1.0.0 is pinned but the manifest asks for the latest"},
+		{Repo: "too/old", Action: ActionRefresh, Reason: "downloaded 30 days ago"},
+		{Repo: "no/date", Action: ActionRefresh, Reason: "unknown download date"},
+		{Repo: "missing/repo", Action: ActionDownload, Reason: "not downloaded yet"},
+		{Repo: "from/url", Action: ActionKeep},
+		{Repo: "kept/latest@routing", Action: ActionRemove, Reason: "not in the manifest"},
+		{Repo: "unlisted/repo", Action: ActionRemove, Reason: "not in the manifest"},
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("Plan = %+v\nwant %+v", changes, want)
+	}
+	for i, change := range changes {
+		repo, err := store.ParseRepo(want[i].Repo)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want[i].Path = repo.File(root)
+		if change.Repo != want[i].Repo || change.Action != want[i].Action || change.Reason != want[i].Reason || change.Path != want[i].Path {
+			t.Errorf("change %d = %+v, want %+v", i, change, want[i])
+		}
+	}
+}
+
+func TestPlanWithoutMaxAge(t *testing.T) {
+	root := newStore(t)
+	writeFile(t, root, "no/date/llms.txt", map[string]string{})
+	writeFile(t, root, "too/old/llms.txt", map[string]string{"DATE_CREATED": "2020-01-01T00:00:00Z"})
+
+	m := &Manifest{Documents: []Entry{{Repo: "no/date"}, {Repo: "too/old"}}}
+	changes, err := Plan(m, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		if change.Action != ActionKeep {
+			t.Errorf("change of %s = %s (%s), want keep when documents never expire", change.Repo, change.Action, change.Reason)
+		}
+	}
+}
+
+func TestPlanEmptyManifestRemovesEverything(t *testing.T) {
+	root := newStore(t)
+	writeFile(t, root, "a/b/llms.txt", map[string]string{})
+	writeFile(t, root, "a/b/llms.topic.txt", map[string]string{})
+
+	changes, err := Plan(&Manifest{}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 || changes[0].Action != ActionRemove || changes[1].Action != ActionRemove {
+		t.Errorf("Plan of an empty manifest = %+v, want both documents removed", changes)
+	}
+}
@@ -0,0 +1,391 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"docs4context-com/internal/snippet"
+)
+
+// Op is the kind of a query node
+type Op int
+
+const (
+	OpTerm Op = iota
+	OpAnd
+	OpOr
+	OpNot
+)
+
+// Node is a parsed query expression
+type Node struct {
+	Op       Op
+	Term     Term
+	Children []*Node
+}
+
+// Term is a single word or quoted phrase, optionally restricted to a field
+type Term struct {
+	// Text is the lowercased word or phrase
+	Text   string
+	Phrase bool
+	// Fields restricts the term to some snippet fields; zero means the
+	// default fields of the tool running the query
+	Fields snippet.FieldMask
+	// Language marks a lang: term, matched against the snippet's LANGUAGE values
+	Language bool
+}
+
+// fieldPrefixes maps the supported "field:" prefixes to the fields they select
+var fieldPrefixes = map[string]snippet.FieldMask{
+	"title":       snippet.MaskTitle,
+	"desc":        snippet.MaskDescription,
+	"description": snippet.MaskDescription,
+	"code":        snippet.MaskCode,
+}
+
+// languagePrefixes are the prefixes that filter on the LANGUAGE of a snippet
+var languagePrefixes = map[string]bool{
+	"lang":     true,
+	"language": true,
+}
+
+// Parse parses a search query. Adjacent terms are combined with AND; the
+// uppercase keywords AND, OR and NOT, a leading "-", parentheses, "quoted
+// phrases" and the prefixes title:, desc:, code: and lang: are supported.
+func Parse(input string) (*Node, error) {
+	tokens, err := lex(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("query is empty")
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in query", p.tokens[p.pos].text)
+	}
+
+	return node, nil
+}
+
+// Language returns a node matching snippets with an example in language
+func Language(language string) *Node {
+	return &Node{Op: OpTerm, Term: Term{Text: snippet.NormalizeLanguage(language), Language: true}}
+}
+
+// Terms returns the terms that must or may be present for the query to
+// match, skipping negated terms and language filters. They are the terms
+// used for scoring and for highlighting matching lines.
+func (n *Node) Terms() []Term {
+	var terms []Term
+	var walk func(*Node)
+	walk = func(node *Node) {
+		switch node.Op {
+		case OpTerm:
+			if !node.Term.Language {
+				terms = append(terms, node.Term)
+			}
+		case OpAnd, OpOr:
+			for _, child := range node.Children {
+				walk(child)
+			}
+		}
+	}
+	walk(n)
+	return terms
+}
+
+// UsesFields reports whether any term of the query, negated or not, searches
+// the given fields once defaults are applied
+func (n *Node) UsesFields(fields, defaults snippet.FieldMask) bool {
+	if n.Op == OpTerm {
+		return !n.Term.Language && n.Term.scope(defaults)&fields != 0
+	}
+	for _, child := range n.Children {
+		if child.UsesFields(fields, defaults) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the snippet satisfies the query. Terms without a
+// field prefix are looked up in the defaults fields.
+func (n *Node) Match(snip *snippet.Snippet, defaults snippet.FieldMask) bool {
+	switch n.Op {
+	case OpTerm:
+		return n.Term.matchSnippet(snip, defaults)
+	case OpAnd:
+		for _, child := range n.Children {
+			if !child.Match(snip, defaults) {
+				return false
+			}
+		}
+		return true
+	case OpOr:
+		for _, child := range n.Children {
+			if child.Match(snip, defaults) {
+				return true
+			}
+		}
+		return false
+	case OpNot:
+		return !n.Children[0].Match(snip, defaults)
+	}
+	return false
+}
+
+// scope returns the fields the term searches
+func (t Term) scope(defaults snippet.FieldMask) snippet.FieldMask {
+	if t.Fields != 0 {
+		return t.Fields
+	}
+	return defaults
+}
+
+// MatchText reports whether text from the given field contains the term
+func (t Term) MatchText(text string, field snippet.Field, defaults snippet.FieldMask) bool {
+	if t.Language || t.scope(defaults)&field.Mask() == 0 {
+		return false
+	}
+	return strings.Contains(strings.ToLower(text), t.Text)
+}
+
+// Count returns how many times the term occurs in text from the given field
+func (t Term) Count(text string, field snippet.Field, defaults snippet.FieldMask) int {
+	if t.Language || t.scope(defaults)&field.Mask() == 0 {
+		return 0
+	}
+	return strings.Count(strings.ToLower(text), t.Text)
+}
+
+func (t Term) matchSnippet(snip *snippet.Snippet, defaults snippet.FieldMask) bool {
+	if t.Language {
+		for _, example := range snip.Examples {
+			if snippet.NormalizeLanguage(example.Language) == t.Text {
+				return true
+			}
+		}
+		return false
+	}
+	for field := snippet.FieldTitle; field <= snippet.FieldCode; field++ {
+		if t.MatchText(snip.Text(field), field, defaults) {
+			return true
+		}
+	}
+	return false
+}
+
+// token is a lexical element of a query
+type token struct {
+	text string
+	// quoted is true for "phrases", which are never treated as keywords
+	quoted bool
+	// prefix is the "field:" prefix attached to the token, if any
+	prefix string
+}
+
+// lex splits a query into words, quoted phrases and parentheses
+func lex(input string) ([]token, error) {
+	var tokens []token
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		if unicode.IsSpace(r) {
+			i++
+			continue
+		}
+		if r == '(' || r == ')' {
+			tokens = append(tokens, token{text: string(r)})
+			i++
+			continue
+		}
+
+		// Read a word up to whitespace, a parenthesis or a quote
+		start := i
+		for i < len(runes) && !unicode.IsSpace(runes[i]) && runes[i] != '(' && runes[i] != ')' && runes[i] != '"' {
+			i++
+		}
+		word := string(runes[start:i])
+
+		// "-title:word" negates a prefixed word: the dashes become tokens of
+		// their own so that the prefix is recognised
+		if negated := strings.TrimLeft(word, "-"); negated != word {
+			if name, _, ok := strings.Cut(negated, ":"); ok && isPrefix(name) {
+				for range len(word) - len(negated) {
+					tokens = append(tokens, token{text: "-"})
+				}
+				word = negated
+			}
+		}
+
+		prefix := ""
+		if name, rest, ok := strings.Cut(word, ":"); ok && isPrefix(name) {
+			prefix = strings.ToLower(name)
+			word = rest
+		}
+
+		// A quote right after a prefix, or on its own, starts a phrase
+		if word == "" && i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted phrase in query")
+			}
+			tokens = append(tokens, token{text: string(runes[i+1 : end]), quoted: true, prefix: prefix})
+			i = end + 1
+			continue
+		}
+		if word == "" {
+			return nil, fmt.Errorf("missing value after %q in query", prefix+":")
+		}
+
+		tokens = append(tokens, token{text: word, prefix: prefix})
+	}
+
+	return tokens, nil
+}
+
+func isPrefix(name string) bool {
+	name = strings.ToLower(name)
+	_, field := fieldPrefixes[name]
+	return field || languagePrefixes[name]
+}
+
+// parser is a recursive descent parser over the lexed tokens
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos >= len(p.tokens) {
+		return token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	tok, ok := p.peek()
+	return ok && !tok.quoted && tok.prefix == "" && tok.text == keyword
+}
+
+// parseOr parses: and ("OR" and)*
+func (p *parser) parseOr() (*Node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Node{left}
+	for p.isKeyword("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &Node{Op: OpOr, Children: children}, nil
+}
+
+// parseAnd parses: unary (["AND"] unary)*
+func (p *parser) parseAnd() (*Node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []*Node{left}
+	for {
+		if p.isKeyword("AND") {
+			p.pos++
+		} else if tok, ok := p.peek(); !ok || p.isKeyword("OR") || (tok.text == ")" && !tok.quoted) {
+			break
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &Node{Op: OpAnd, Children: children}, nil
+}
+
+// parseUnary parses: ("NOT" | "-") unary | primary
+func (p *parser) parseUnary() (*Node, error) {
+	tok, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("query ends unexpectedly")
+	}
+	if p.isKeyword("NOT") {
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpNot, Children: []*Node{child}}, nil
+	}
+	if !tok.quoted && tok.prefix == "" && strings.HasPrefix(tok.text, "-") {
+		// "-word" negates the word, a lone "-" negates what follows
+		if tok.text == "-" {
+			p.pos++
+		} else {
+			p.tokens[p.pos].text = tok.text[1:]
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Node{Op: OpNot, Children: []*Node{child}}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary parses: "(" or ")" | term
+func (p *parser) parsePrimary() (*Node, error) {
+	tok, _ := p.peek()
+	p.pos++
+
+	if !tok.quoted && tok.text == "(" {
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.quoted || closing.text != ")" {
+			return nil, fmt.Errorf("missing closing parenthesis in query")
+		}
+		p.pos++
+		return node, nil
+	}
+	if !tok.quoted && tok.prefix == "" && (tok.text == ")" || tok.text == "AND" || tok.text == "OR") {
+		return nil, fmt.Errorf("unexpected %q in query", tok.text)
+	}
+
+	term := Term{Text: strings.ToLower(tok.text), Phrase: tok.quoted}
+	if languagePrefixes[tok.prefix] {
+		term.Language = true
+		term.Text = snippet.NormalizeLanguage(tok.text)
+	} else if tok.prefix != "" {
+		term.Fields = fieldPrefixes[tok.prefix]
+	}
+	if strings.TrimSpace(term.Text) == "" {
+		return nil, fmt.Errorf("empty phrase in query")
+	}
+
+	return &Node{Op: OpTerm, Term: term}, nil
+}
@@ -0,0 +1,184 @@
+package query
+
+import (
+	"strings"
+	"testing"
+
+	"docs4context-com/internal/snippet"
+)
+
+// render writes a node as an s-expression, with quotes around phrases and
+// the field or language of a prefixed term before it
+func render(n *Node) string {
+	switch n.Op {
+	case OpTerm:
+		text := n.Term.Text
+		if n.Term.Phrase {
+			text = `"` + text + `"`
+		}
+		switch {
+		case n.Term.Language:
+			return "(lang " + text + ")"
+		case n.Term.Fields == snippet.MaskTitle:
+			return "(title " + text + ")"
+		case n.Term.Fields == snippet.MaskDescription:
+			return "(desc " + text + ")"
+		case n.Term.Fields == snippet.MaskCode:
+			return "(code " + text + ")"
+		}
+		return text
+	case OpNot:
+		return "(not " + render(n.Children[0]) + ")"
+	}
+	op := "and"
+	if n.Op == OpOr {
+		op = "or"
+	}
+	parts := []string{op}
+	for _, child := range n.Children {
+		parts = append(parts, render(child))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParse(t *testing.T) {
+	tests := map[string]string{
+		// Terms are lowercased and combined with AND
+		"Server":                 "server",
+		"stdio server":           "(and stdio server)",
+		"stdio AND server":       "(and stdio server)",
+		"a b AND c":              "(and a b c)",
+		"recovery OR middleware": "(or recovery middleware)",
+		// AND binds tighter than OR, and parentheses override it
+		"a b OR c":          "(or (and a b) c)",
+		"a OR b c":          "(or a (and b c))",
+		"a OR b AND c OR d": "(or a (and b c) d)",
+		"(a OR b) c":        "(and (or a b) c)",
+		"a (b OR (c d))":    "(and a (or b (and c d)))",
+		"((a))":             "a",
+		// NOT and - negate the next operand only
+		"NOT a":         "(not a)",
+		"-a b":          "(and (not a) b)",
+		"a -b":          "(and a (not b))",
+		"a - b":         "(and a (not b))",
+		"NOT NOT a":     "(not (not a))",
+		"--a":           "(not (not a))",
+		"NOT (a OR b)":  "(not (or a b))",
+		"-(a b) c":      "(and (not (and a b)) c)",
+		"a OR NOT b":    "(or a (not b))",
+		"server-side":   "server-side",
+		"NOT a OR b":    "(or (not a) b)",
+		"-title:beta a": "(and (not (title beta)) a)",
+		"--title:beta":  "(not (not (title beta)))",
+		"a -lang:go":    "(and a (not (lang go)))",
+		"-foo:bar":      "(not foo:bar)",
+		// Phrases keep their spaces and are never keywords
+		`"stdio transport"`:        `"stdio transport"`,
+		`"OR"`:                     `"or"`,
+		`"Stdio Transport" server`: `(and "stdio transport" server)`,
+		`a "NOT" b`:                `(and a "not" b)`,
+		`a"b c"`:                   `(and a "b c")`,
+		// Keywords are uppercase only
+		"a or b":  "(and a or b)",
+		"a and b": "(and a and b)",
+		"not a":   "(and not a)",
+		// Field and language prefixes, case-insensitively
+		"title:server":              "(title server)",
+		"TITLE:Server":              "(title server)",
+		"desc:transport":            "(desc transport)",
+		"description:transport":     "(desc transport)",
+		"code:NewMCPServer":         "(code newmcpserver)",
+		`title:"stdio transport"`:   `(title "stdio transport")`,
+		`-title:"stdio transport"`:  `(not (title "stdio transport"))`,
+		"lang:golang":               "(lang go)",
+		"language:TS":               "(lang typescript)",
+		"server lang:go -code:beta": "(and server (lang go) (not (code beta)))",
+		// Unknown prefixes are part of the word
+		"http://example.com": "http://example.com",
+		"foo:bar":            "foo:bar",
+	}
+	for input, want := range tests {
+		node, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %v", input, err)
+			continue
+		}
+		if got := render(node); got != want {
+			t.Errorf("Parse(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	malformed := []string{
+		"",
+		"   ",
+		"(",
+		")",
+		"a)",
+		"(a",
+		"(a OR b",
+		"()",
+		"a OR",
+		"OR a",
+		"a AND",
+		"AND a",
+		"a OR OR b",
+		"NOT",
+		"a NOT",
+		"-",
+		`"unterminated`,
+		`a "b`,
+		`""`,
+		`"   "`,
+		"title:",
+		"lang:",
+		"title: a",
+		"-title:",
+	}
+	for _, input := range malformed {
+		if node, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", input, render(node))
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	snip := &snippet.Snippet{
+		Title:       "Stdio transport",
+		Description: "Serve MCP over standard input and output",
+		Examples:    []snippet.Example{{Language: "Go", Code: "server.ServeStdio(s)"}},
+	}
+	tests := map[string]bool{
+		"stdio":                    true,
+		"STDIO transport":          true,
+		"transport -stdio":         false,
+		"sse OR stdio":             true,
+		"sse OR http":              false,
+		`"stdio transport"`:        true,
+		`"transport stdio"`:        false,
+		"title:serve":              false,
+		"desc:serve":               true,
+		"code:servestdio":          true,
+		"lang:golang":              true,
+		"lang:python":              false,
+		"NOT (sse OR http) stdio":  true,
+		"(sse OR stdio) -lang:go":  false,
+		"input output -deprecated": true,
+	}
+	for input, want := range tests {
+		node, err := Parse(input)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", input, err)
+		}
+		if got := node.Match(snip, snippet.MaskAll); got != want {
+			t.Errorf("Match(%q) = %v, want %v", input, got, want)
+		}
+	}
+
+	// Terms without a prefix only search the default fields
+	node, _ := Parse("serve")
+	if node.Match(snip, snippet.MaskTitle) {
+		t.Error("Match(serve) on titles matched the description")
+	}
+}
@@ -0,0 +1,271 @@
+package savecontext
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/source"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultConcurrency and MaxConcurrency bound the number of documents a
+// batch downloads at a time
+const (
+	DefaultConcurrency = 4
+	MaxConcurrency     = 8
+)
+
+// BatchResult is the outcome of one entry of a batch
+type BatchResult struct {
+	Target string  `json:"target"`
+	OK     bool    `json:"ok"`
+	Result *Result `json:"result,omitempty"`
+	Error  string  `json:"error,omitempty"`
+	// DuplicateOf is the target of an earlier entry saving the same
+	// document, when this entry was skipped for it
+	DuplicateOf string `json:"duplicate_of,omitempty"`
+}
+
+// batchResponse is the JSON form of batch_save_context_documents
+type batchResponse struct {
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// AddBatchTool adds the tool downloading many documents in one call
+func AddBatchTool(s *server.MCPServer) {
+	batchTool := mcp.NewTool("batch_save_context_documents",
+		mcp.WithDescription("Download and save the context documents of many repositories at once, concurrently, returning a success or failure summary per repository"),
+		mcp.WithArray("repos",
+			mcp.Description("Repositories to download, each as accepted by save_context_document's github_url: a GitHub URL, 'owner/repo' or the URL of an llms.txt document"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithString("manifest",
+			mcp.Description("Path to a text file listing repositories to download, one per line; blank lines and lines starting with '#' are skipped"),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of documents to download at a time (default %d, at most %d)", DefaultConcurrency, MaxConcurrency)),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Store to save to: 'project' (default) or 'global'"),
+			mcp.Enum(store.ScopeProject, store.ScopeGlobal),
+		),
+		mcp.WithString("output_dir",
+			mcp.Description("Output directory for the documents, overriding 'scope'"),
+		),
+		mcp.WithString("source",
+			mcp.Description(fmt.Sprintf("Source to download repositories from: one of %s (default '%s')", strings.Join(source.Names(), ", "), source.Default)),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(batchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool called")
+
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		targets := request.GetStringSlice("repos", nil)
+		if manifest := request.GetString("manifest", ""); manifest != "" {
+			listed, err := ReadManifest(manifest)
+			if err != nil {
+				log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool error - invalid parameter 'manifest': %v", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			targets = append(targets, listed...)
+		}
+		if len(targets) == 0 {
+			log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool error - no repositories given")
+			return mcp.NewToolResultError("no repositories given: pass 'repos' or 'manifest'"), nil
+		}
+
+		concurrency := request.GetInt("concurrency", DefaultConcurrency)
+		if concurrency < 1 || concurrency > MaxConcurrency {
+			log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool error - invalid parameter 'concurrency': %d", concurrency)
+			return mcp.NewToolResultError(fmt.Sprintf("concurrency must be between 1 and %d", MaxConcurrency)), nil
+		}
+
+		outputDir, err := ResolveOutputDir(request.GetString("output_dir", ""), request.GetString("scope", store.DefaultScope))
+		if err != nil {
+			log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool error - invalid output location: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		results := Batch(ctx, Requests(targets, Request{Source: request.GetString("source", ""), OutputDir: outputDir}), concurrency)
+		succeeded, failed := CountBatch(results)
+		log.Printf("BATCH_SAVE_CONTEXT_DOCUMENTS tool: %d succeeded, %d failed", succeeded, failed)
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(batchResponse{Succeeded: succeeded, Failed: failed, Results: results})
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(FormatBatch(results)), nil
+	})
+}
+
+// Requests builds one request per target, each as accepted by
+// Request.Target, with the other settings of template
+func Requests(targets []string, template Request) []Request {
+	requests := make([]Request, 0, len(targets))
+	for _, target := range targets {
+		req := template
+		req.Target = strings.TrimSpace(target)
+		requests = append(requests, req)
+	}
+	return requests
+}
+
+// Batch saves the documents of requests with at most concurrency downloads
+// at a time. Requests saving the same document, however they name it, are
+// saved once and the later ones are reported as duplicates. The results are
+// in the order of requests.
+func Batch(ctx context.Context, requests []Request, concurrency int) []BatchResult {
+	results := make([]BatchResult, len(requests))
+	var unique []int
+	first := make(map[string]int)
+	for i, req := range requests {
+		path, ok := targetPath(req)
+		if !ok {
+			unique = append(unique, i)
+			continue
+		}
+		if j, seen := first[path]; seen {
+			results[i] = BatchResult{Target: req.Target, DuplicateOf: requests[j].Target}
+			continue
+		}
+		first[path] = i
+		unique = append(unique, i)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(unique); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				req := requests[i]
+				result, err := Save(ctx, req)
+				if err != nil {
+					log.Printf("Batch: failed to save %s: %v", req.Target, err)
+					results[i] = BatchResult{Target: req.Target, Error: err.Error()}
+					continue
+				}
+				results[i] = BatchResult{Target: req.Target, OK: true, Result: &result}
+			}
+		}()
+	}
+	for _, i := range unique {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	updated := make(map[string]bool)
+	for _, i := range unique {
+		req := requests[i]
+		if !updated[req.OutputDir] {
+			updated[req.OutputDir] = true
+			UpdateStore(req.OutputDir)
+		}
+	}
+	return results
+}
+
+// targetPath returns the file a request saves its document to, or false
+// when the request is invalid and left to fail in Save
+func targetPath(req Request) (string, bool) {
+	if req.Target == "" {
+		return "", false
+	}
+	repoID, err := TargetRepo(req)
+	if err != nil {
+		return "", false
+	}
+	dir, err := filepath.Abs(req.OutputDir)
+	if err != nil {
+		return "", false
+	}
+	return repoID.File(dir), true
+}
+
+// CountBatch returns the number of succeeded and failed entries of a batch;
+// duplicates count as neither
+func CountBatch(results []BatchResult) (succeeded, failed int) {
+	for _, result := range results {
+		switch {
+		case result.OK:
+			succeeded++
+		case result.DuplicateOf == "":
+			failed++
+		}
+	}
+	return succeeded, failed
+}
+
+// FormatBatch renders the per-repository summary of a batch as text
+func FormatBatch(results []BatchResult) string {
+	succeeded, failed := CountBatch(results)
+	lines := []string{fmt.Sprintf("=== Batch Download: %d succeeded, %d failed ===", succeeded, failed), ""}
+	for _, result := range results {
+		switch {
+		case result.OK:
+			lines = append(lines, fmt.Sprintf("✅ %s -> %s (%d tokens)", result.Result.Repo, result.Result.Path, result.Result.TokenCount))
+		case result.DuplicateOf != "":
+			lines = append(lines, fmt.Sprintf("⏭️ %s: skipped as a duplicate of %s", result.Target, result.DuplicateOf))
+		default:
+			lines = append(lines, fmt.Sprintf("❌ %s: %s", result.Target, result.Error))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ReadManifest reads a list of repositories, one per line, skipping blank
+// lines and lines starting with '#'. Lines that are neither a repository
+// nor a document URL are rejected by line number, without echoing them.
+func ReadManifest(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest: %v", err)
+	}
+	defer f.Close()
+
+	var targets []string
+	scanner := bufio.NewScanner(f)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, ok := DocumentURL(line); !ok {
+			if _, _, err := ParseGitHubURL(line); err != nil {
+				return nil, fmt.Errorf("manifest %s, line %d: not a repository or document URL", path, number)
+			}
+		}
+		targets = append(targets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %v", err)
+	}
+	return targets, nil
+}
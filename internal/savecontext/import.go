@@ -0,0 +1,248 @@
+package savecontext
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+	"docs4context-com/internal/tokens"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ImportResult describes a document written by Import
+type ImportResult struct {
+	Repo       string `json:"repo"`
+	Source     string `json:"source"`
+	Path       string `json:"path"`
+	Snippets   int    `json:"snippets"`
+	TokenCount int    `json:"token_count"`
+	Size       int    `json:"size_bytes"`
+}
+
+// importResponse is the JSON form of import_context_document
+type importResponse struct {
+	Store     string         `json:"store"`
+	Documents []ImportResult `json:"documents"`
+}
+
+// pendingImport is a validated document waiting to be written
+type pendingImport struct {
+	repo    store.Repo
+	source  string
+	content []byte
+	doc     *snippet.Document
+}
+
+// AddImportTool adds the tool importing local llms.txt files into the store
+func AddImportTool(s *server.MCPServer) {
+	importTool := mcp.NewTool("import_context_document",
+		mcp.WithDescription("Import local llms.txt files, e.g. generated for private libraries, into the store so the search tools find them"),
+		mcp.WithString("path",
+			mcp.Required(),
+			mcp.Description("An llms.txt file, or a directory holding owner/repo/llms.txt files"),
+		),
+		mcp.WithString("repo",
+			mcp.Description("Repository (owner/name) to store a single file under; defaults to the REPO of its metadata header"),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Store to import into: 'project' (default) or 'global'"),
+			mcp.Enum(store.ScopeProject, store.ScopeGlobal),
+		),
+		mcp.WithString("output_dir",
+			mcp.Description("Output directory for the imported documents, overriding 'scope'"),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(importTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("IMPORT_CONTEXT_DOCUMENT tool called")
+
+		path, err := request.RequireString("path")
+		if err != nil {
+			log.Printf("IMPORT_CONTEXT_DOCUMENT tool error - invalid parameter 'path': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("IMPORT_CONTEXT_DOCUMENT tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		outputDir, err := ResolveOutputDir(request.GetString("output_dir", ""), request.GetString("scope", store.DefaultScope))
+		if err != nil {
+			log.Printf("IMPORT_CONTEXT_DOCUMENT tool error - invalid output location: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		results, err := Import(path, request.GetString("repo", ""), outputDir)
+		if err != nil {
+			log.Printf("IMPORT_CONTEXT_DOCUMENT tool error - failed to import %s: %v", path, err)
+			return mcp.NewToolResultError(fmt.Sprintf("failed to import %s: %v", path, err)), nil
+		}
+
+		if outputFormat == format.JSON {
+			result, err := format.Marshal(importResponse{Store: store.Scope(outputDir), Documents: results})
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, result), nil
+		}
+
+		var lines []string
+		for _, imported := range results {
+			lines = append(lines, fmt.Sprintf("Imported %s from %s to %s\nSnippets: %d\nTokens: %d\nSize: %d bytes", imported.Repo, imported.Source, imported.Path, imported.Snippets, imported.TokenCount, imported.Size))
+		}
+		return mcp.NewToolResultText(strings.Join(lines, "\n\n")), nil
+	})
+}
+
+// Import validates the llms.txt file or directory at path and writes it into
+// outputDir with the same metadata header as downloaded documents. A file is
+// stored under repo, or the REPO of its own metadata header when repo is
+// empty. A directory is either a single document's directory holding an
+// llms.txt file, or laid out like a store with owner/repo/llms.txt files.
+// Every document is validated before any is written.
+func Import(path, repo, outputDir string) ([]ImportResult, error) {
+	pending, err := collectImports(path, repo)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ImportResult
+	for _, item := range pending {
+		tokenCount, err := countTokens(item.content)
+		if err != nil {
+			log.Printf("IMPORT_CONTEXT_DOCUMENT warning - failed to count tokens: %v, using an estimate", err)
+			tokenCount = tokens.Estimate(string(item.content))
+		}
+
+		outputPath := item.repo.File(outputDir)
+		if err := store.CheckDir(filepath.Dir(outputPath)); err != nil {
+			return results, err
+		}
+		if err := saveDocument(outputPath, item.content, documentHeader{Repo: item.repo, TokenCount: tokenCount, Source: item.source}); err != nil {
+			return results, err
+		}
+		log.Printf("Imported %s from %s to %s (%d tokens)", item.repo, item.source, outputPath, tokenCount)
+
+		results = append(results, ImportResult{
+			Repo:       item.repo.String(),
+			Source:     item.source,
+			Path:       outputPath,
+			Snippets:   len(item.doc.Snippets),
+			TokenCount: tokenCount,
+			Size:       len(item.content),
+		})
+	}
+
+	UpdateStore(outputDir)
+
+	return results, nil
+}
+
+// collectImports reads and validates the documents to import from path
+func collectImports(path, repo string) ([]pendingImport, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		item, err := readImport(path, repo)
+		if err != nil {
+			return nil, err
+		}
+		return []pendingImport{item}, nil
+	}
+
+	single := filepath.Join(path, store.DocumentFile)
+	if info, err := os.Stat(single); err == nil && info.Mode().IsRegular() {
+		item, err := readImport(single, repo)
+		if err != nil {
+			return nil, err
+		}
+		return []pendingImport{item}, nil
+	}
+
+	if repo != "" {
+		return nil, fmt.Errorf("'repo' can only be given for a single document, but %s holds owner/repo/%s files", path, store.DocumentFile)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(path, "*", "*", "llms*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	var pending []pendingImport
+	for _, match := range matches {
+		rel, err := filepath.Rel(path, match)
+		if err != nil {
+			return nil, err
+		}
+		repoID, ok := store.RepoFromPath(rel)
+		if !ok {
+			continue
+		}
+		item, err := readImport(match, repoID.String())
+		if err != nil {
+			return nil, err
+		}
+		pending = append(pending, item)
+	}
+	if len(pending) == 0 {
+		return nil, fmt.Errorf("no %s or owner/repo/%s files found in %s", store.DocumentFile, store.DocumentFile, path)
+	}
+	return pending, nil
+}
+
+// readImport reads and validates one llms.txt file. A metadata header from
+// a previous save is replaced, so only the snippets are kept.
+func readImport(path, repo string) (pendingImport, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return pendingImport{}, fmt.Errorf("failed to resolve %s: %v", path, err)
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return pendingImport{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return pendingImport{}, fmt.Errorf("%s is not a regular file", abs)
+	}
+	content, err := os.ReadFile(abs)
+	if err != nil {
+		return pendingImport{}, fmt.Errorf("failed to read file %s: %v", abs, err)
+	}
+
+	doc := snippet.Parse(content)
+	if err := doc.Validate(); err != nil {
+		return pendingImport{}, fmt.Errorf("%s is not a valid llms.txt file: %v", abs, err)
+	}
+	if len(doc.Lines) > 0 && strings.TrimSpace(doc.Lines[0]) == "# METADATA" {
+		content = []byte(strings.Join(doc.Lines[doc.Metadata.HeaderLines:], "\n"))
+	}
+
+	if repo == "" {
+		repo = doc.Metadata.Repo
+	}
+	if repo == "" {
+		return pendingImport{}, fmt.Errorf("no repository for %s: pass 'repo' as owner/name", abs)
+	}
+	repoID, err := store.ParseRepo(repo)
+	if err != nil {
+		return pendingImport{}, err
+	}
+
+	return pendingImport{repo: repoID, source: abs, content: content, doc: doc}, nil
+}
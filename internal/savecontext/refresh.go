@@ -0,0 +1,494 @@
+package savecontext
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/source"
+	"docs4context-com/internal/store"
+	"docs4context-com/internal/tokens"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// DefaultMaxAgeDays is the age in days after which refresh downloads a
+// document again
+const DefaultMaxAgeDays = 7
+
+// Outcomes of refreshing a document
+const (
+	RefreshUpdated   = "updated"
+	RefreshUnchanged = "unchanged"
+	RefreshStale     = "stale"
+	RefreshFresh     = "fresh"
+	RefreshFailed    = "failed"
+)
+
+// RefreshResult is the outcome of refreshing one stored document
+type RefreshResult struct {
+	Repo    string `json:"repo"`
+	Path    string `json:"path"`
+	Source  string `json:"source"`
+	Status  string `json:"status"`
+	AgeDays int    `json:"age_days"`
+	// Hash is the SHA-256 of the document's content without its header
+	Hash       string `json:"hash,omitempty"`
+	TokenCount int    `json:"token_count,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// refreshResponse is the JSON form of refresh_context_documents
+type refreshResponse struct {
+	Store      string          `json:"store"`
+	MaxAgeDays int             `json:"max_age_days"`
+	DryRun     bool            `json:"dry_run"`
+	Results    []RefreshResult `json:"results"`
+}
+
+// AddRefreshTool adds the tool downloading stale documents again
+func AddRefreshTool(s *server.MCPServer) {
+	refreshTool := mcp.NewTool("refresh_context_documents",
+		mcp.WithDescription("Download stored context documents older than a given age again from the source they were saved from, rewriting only the documents whose content changed, and report what was updated"),
+		mcp.WithNumber("max_age_days",
+			mcp.Description(fmt.Sprintf("Refresh documents downloaded or last checked more than this many days ago (default %d; 0 refreshes every document)", DefaultMaxAgeDays)),
+		),
+		mcp.WithArray("repos",
+			mcp.Description("Only refresh these repositories, as 'owner/repo' or 'owner/repo@variant'"),
+			mcp.Items(map[string]any{"type": "string"}),
+		),
+		mcp.WithBoolean("dry_run",
+			mcp.Description("Only report which documents are stale, without downloading anything"),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of documents to download at a time (default %d, at most %d)", DefaultConcurrency, MaxConcurrency)),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Store to refresh: 'project' (default) or 'global'"),
+			mcp.Enum(store.ScopeProject, store.ScopeGlobal),
+		),
+		mcp.WithString("output_dir",
+			mcp.Description("Directory of documents to refresh, overriding 'scope'"),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(refreshTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("REFRESH_CONTEXT_DOCUMENTS tool called")
+
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("REFRESH_CONTEXT_DOCUMENTS tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		maxAgeDays := request.GetInt("max_age_days", DefaultMaxAgeDays)
+		if maxAgeDays < 0 {
+			log.Printf("REFRESH_CONTEXT_DOCUMENTS tool error - invalid parameter 'max_age_days': %d", maxAgeDays)
+			return mcp.NewToolResultError("max_age_days must not be negative"), nil
+		}
+
+		concurrency := request.GetInt("concurrency", DefaultConcurrency)
+		if concurrency < 1 || concurrency > MaxConcurrency {
+			log.Printf("REFRESH_CONTEXT_DOCUMENTS tool error - invalid parameter 'concurrency': %d", concurrency)
+			return mcp.NewToolResultError(fmt.Sprintf("concurrency must be between 1 and %d", MaxConcurrency)), nil
+		}
+
+		dir, err := ResolveOutputDir(request.GetString("output_dir", ""), request.GetString("scope", store.DefaultScope))
+		if err != nil {
+			log.Printf("REFRESH_CONTEXT_DOCUMENTS tool error - invalid output location: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		dryRun := request.GetBool("dry_run", false)
+		results, err := Refresh(ctx, dir, request.GetStringSlice("repos", nil), maxAgeDays, dryRun, concurrency)
+		if err != nil {
+			log.Printf("REFRESH_CONTEXT_DOCUMENTS tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(refreshResponse{Store: dir, MaxAgeDays: maxAgeDays, DryRun: dryRun, Results: results})
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(FormatRefresh(dir, results, dryRun)), nil
+	})
+}
+
+// Refresh downloads the documents in dir that are older than maxAgeDays
+// again, with at most concurrency downloads at a time, and rewrites those
+// whose content changed. The age of a document counts from the later of its
+// DATE_CREATED and its modification time, which records when an unchanged
+// document was last checked. repos, when given, limits the documents
+// refreshed. With dryRun the stale documents are only reported.
+func Refresh(ctx context.Context, dir string, repos []string, maxAgeDays int, dryRun bool, concurrency int) ([]RefreshResult, error) {
+	wanted := make(map[string]bool)
+	for _, repo := range repos {
+		repoID, err := store.ParseRepo(strings.TrimSpace(repo))
+		if err != nil {
+			return nil, err
+		}
+		wanted[repoID.String()] = true
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*", "*", "llms*.txt"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+
+	var results []RefreshResult
+	var stale []int
+	for _, match := range matches {
+		rel, err := filepath.Rel(dir, match)
+		if err != nil {
+			return nil, err
+		}
+		repoID, ok := store.RepoFromPath(rel)
+		if !ok || (len(wanted) > 0 && !wanted[repoID.String()]) {
+			continue
+		}
+		info, err := os.Lstat(match)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		result := RefreshResult{Repo: repoID.String(), Path: match, Status: RefreshFresh}
+		doc, err := snippet.ParseFile(match)
+		if err != nil {
+			result.Status, result.Error = RefreshFailed, err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Source = doc.Metadata.Source
+
+		checked := info.ModTime()
+		if created, err := time.Parse(time.RFC3339, doc.Metadata.DateCreated); err == nil && created.After(checked) {
+			checked = created
+		}
+		age := time.Since(checked)
+		result.AgeDays = int(age.Hours() / 24)
+		if age >= time.Duration(maxAgeDays)*24*time.Hour {
+			result.Status = RefreshStale
+			stale = append(stale, len(results))
+		}
+		results = append(results, result)
+	}
+	for _, name := range sortedKeys(wanted) {
+		if !containsRepo(results, name) {
+			return nil, fmt.Errorf("%s is not stored in %s", name, dir)
+		}
+	}
+	if dryRun || len(stale) == 0 {
+		return results, nil
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency && worker < len(stale); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				refreshDocument(ctx, &results[i])
+			}
+		}()
+	}
+	for _, i := range stale {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	UpdateStore(dir)
+	return results, nil
+}
+
+// refreshDocument downloads one stored document again and rewrites it when
+// its content changed. The download is conditional on the validators in the
+// header, so a server answering 304 Not Modified sends no content at all.
+func refreshDocument(ctx context.Context, result *RefreshResult) {
+	fail := func(err error) {
+		log.Printf("Refresh: failed to refresh %s: %v", result.Repo, err)
+		result.Status, result.Error = RefreshFailed, err.Error()
+	}
+
+	stored, err := os.ReadFile(result.Path)
+	if err != nil {
+		fail(err)
+		return
+	}
+	meta := snippet.Parse(stored).Metadata
+	repoID, err := store.ParseRepo(result.Repo)
+	if err != nil {
+		fail(err)
+		return
+	}
+	header := documentHeader{
+		Repo:         repoID,
+		TokenCount:   meta.TokenCount,
+		Source:       result.Source,
+		Version:      meta.Fields["VERSION"],
+		ETag:         meta.Fields["ETAG"],
+		LastModified: meta.Fields["LAST_MODIFIED"],
+		DateCreated:  meta.DateCreated,
+	}
+	unchanged := func() {
+		result.Status, result.AgeDays, result.TokenCount = RefreshUnchanged, 0, meta.TokenCount
+	}
+
+	document, err := fetchSource(ctx, repoID, result.Source, meta.TokenCount, source.FetchOptions{ETag: header.ETag, LastModified: header.LastModified})
+	if errors.Is(err, source.ErrNotModified) {
+		log.Printf("Refresh: %s not modified", result.Repo)
+		touch(result.Path)
+		unchanged()
+		return
+	}
+	if err != nil {
+		fail(err)
+		return
+	}
+	result.Hash = contentHash(document.Content)
+
+	if result.Hash == contentHash(stripHeader(stored)) {
+		if document.ETag == header.ETag && document.LastModified == header.LastModified && document.URL == header.Source {
+			touch(result.Path)
+			unchanged()
+			return
+		}
+		// Keep the content and its date, recording the new validators and
+		// the URL they belong to so the next refresh can be conditional
+		header.Source, header.ETag, header.LastModified = document.URL, document.ETag, document.LastModified
+		if err := writeRefreshed(result.Path, document.Content, header); err != nil {
+			fail(err)
+			return
+		}
+		unchanged()
+		return
+	}
+
+	tokenCount, err := countTokens(document.Content)
+	if err != nil {
+		log.Printf("Refresh: failed to count tokens of %s: %v, using an estimate", result.Repo, err)
+		tokenCount = tokens.Estimate(string(document.Content))
+	}
+	header.TokenCount, header.DateCreated = tokenCount, ""
+	header.Source, header.ETag, header.LastModified = document.URL, document.ETag, document.LastModified
+	if err := writeRefreshed(result.Path, document.Content, header); err != nil {
+		fail(err)
+		return
+	}
+	log.Printf("Refresh: updated %s from %s (%d tokens)", result.Repo, result.Source, tokenCount)
+	result.Status, result.AgeDays, result.TokenCount = RefreshUpdated, 0, tokenCount
+}
+
+// writeRefreshed writes a refreshed document back to its path in the store
+func writeRefreshed(path string, content []byte, header documentHeader) error {
+	if err := store.CheckDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return saveDocument(path, content, header)
+}
+
+// touch records when an unchanged document was last checked
+func touch(path string) {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		log.Printf("Warning - failed to record the check of %s: %v", path, err)
+	}
+}
+
+// FetchLatest downloads the stored document of repo at path again from the
+// source recorded in its header, as Refresh does, returning it in the
+// snippet format without writing anything
+func FetchLatest(ctx context.Context, repo store.Repo, path string) (*source.Document, error) {
+	doc, err := snippet.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fetchSource(ctx, repo, doc.Metadata.Source, doc.Metadata.TokenCount, source.FetchOptions{})
+}
+
+// fetchSource downloads a document again from the SOURCE of its header: the
+// URL it was downloaded from, or the local file it was read or imported
+// from. A context7 document is requested again through the context7 source,
+// for at least tokenCount tokens, since its URL may not name the size of
+// the document. The content is returned in the snippet format.
+func fetchSource(ctx context.Context, repo store.Repo, documentSource string, tokenCount int, opts source.FetchOptions) (*source.Document, error) {
+	switch {
+	case strings.HasPrefix(documentSource, "http://") || strings.HasPrefix(documentSource, "https://"):
+		src := source.ForURL(documentSource)
+		if context7Opts, ok := context7Request(documentSource, repo, tokenCount); ok {
+			context7Source, err := source.Get(source.Context7)
+			if err != nil {
+				return nil, err
+			}
+			src = context7Source
+			// The validators were recorded for SOURCE; sent with a request
+			// for another URL, a 304 would keep a document that differs
+			// from the one requested
+			if requestURL, ok := source.DocumentURL(src, repo, context7Opts); ok && requestURL == documentSource {
+				context7Opts.ETag, context7Opts.LastModified = opts.ETag, opts.LastModified
+			}
+			opts = context7Opts
+		}
+		document, err := src.FetchDocument(ctx, repo, opts)
+		if err != nil {
+			return nil, err
+		}
+		document.Content, err = normalize(document.Content, document.URL)
+		return document, err
+	case filepath.IsAbs(documentSource):
+		info, err := os.Stat(documentSource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %v", err)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("failed to read document: %s is not a regular file", documentSource)
+		}
+		content, err := os.ReadFile(documentSource)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read document: %v", err)
+		}
+		content, err = normalize(stripHeader(content), documentSource)
+		return &source.Document{Content: content, URL: documentSource}, err
+	default:
+		return nil, fmt.Errorf("the document records no source to refresh it from")
+	}
+}
+
+// context7Request returns the options requesting the context7 document at
+// documentURL again: its version and topic, and a token limit of at least
+// tokenCount and the default, so that a document saved without a limit is
+// not cut short. The limit in the URL is kept for a variant limited to it.
+func context7Request(documentURL string, repo store.Repo, tokenCount int) (source.FetchOptions, bool) {
+	parsed, err := url.Parse(documentURL)
+	if err != nil {
+		return source.FetchOptions{}, false
+	}
+	var path string
+	for _, base := range []string{store.Context7URL(), store.DefaultContext7URL} {
+		baseURL, err := url.Parse(base)
+		if err != nil || parsed.Scheme != baseURL.Scheme || parsed.Host != baseURL.Host {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(parsed.EscapedPath(), strings.TrimSuffix(baseURL.EscapedPath(), "/")+"/"); ok {
+			path = rest
+			break
+		}
+	}
+
+	// owner/repo/llms.txt or owner/repo/version/llms.txt
+	parts := strings.Split(path, "/")
+	if (len(parts) != 3 && len(parts) != 4) || parts[len(parts)-1] != "llms.txt" || parts[0] != repo.Owner || parts[1] != repo.Name {
+		return source.FetchOptions{}, false
+	}
+	var opts source.FetchOptions
+	if len(parts) == 4 {
+		if opts.Version, err = url.PathUnescape(parts[2]); err != nil {
+			return source.FetchOptions{}, false
+		}
+	}
+	query := parsed.Query()
+	opts.Topic = query.Get("topic")
+
+	limit, _ := strconv.Atoi(query.Get("tokens"))
+	if limit > 0 && strings.HasSuffix(repo.Variant, fmt.Sprintf("tokens-%d", limit)) {
+		opts.MaxTokens = limit
+	} else {
+		opts.MaxTokens = max(source.DefaultTokenCount, tokenCount, limit)
+	}
+	return opts, true
+}
+
+// stripHeader removes the metadata header written by saveDocument
+func stripHeader(content []byte) []byte {
+	if !bytes.HasPrefix(content, []byte("# METADATA\n")) {
+		return content
+	}
+	if end := bytes.Index(content, []byte("\n#\n")); end >= 0 {
+		return content[end+len("\n#\n"):]
+	}
+	return content
+}
+
+// contentHash returns the SHA-256 of content in hex
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// containsRepo reports whether results hold the document of repo
+func containsRepo(results []RefreshResult, repo string) bool {
+	for _, result := range results {
+		if result.Repo == repo {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FormatRefresh renders the result of a refresh as text
+func FormatRefresh(dir string, results []RefreshResult, dryRun bool) string {
+	counts := make(map[string]int)
+	for _, result := range results {
+		counts[result.Status]++
+	}
+
+	title := fmt.Sprintf("=== Refresh of %s: %d updated, %d unchanged, %d fresh, %d failed ===",
+		dir, counts[RefreshUpdated], counts[RefreshUnchanged], counts[RefreshFresh], counts[RefreshFailed])
+	if dryRun {
+		title = fmt.Sprintf("=== Refresh plan for %s (dry run): %d stale, %d fresh ===", dir, counts[RefreshStale], counts[RefreshFresh])
+	}
+
+	lines := []string{title, ""}
+	for _, result := range results {
+		switch result.Status {
+		case RefreshUpdated:
+			lines = append(lines, fmt.Sprintf("~ %s: updated from %s (%d tokens)", result.Repo, result.Source, result.TokenCount))
+		case RefreshUnchanged:
+			lines = append(lines, fmt.Sprintf("= %s: unchanged", result.Repo))
+		case RefreshStale:
+			lines = append(lines, fmt.Sprintf("~ %s: would refresh (checked %d days ago)", result.Repo, result.AgeDays))
+		case RefreshFresh:
+			lines = append(lines, fmt.Sprintf("= %s: fresh (checked %d days ago)", result.Repo, result.AgeDays))
+		case RefreshFailed:
+			lines = append(lines, fmt.Sprintf("! %s: failed to refresh: %s", result.Repo, result.Error))
+		}
+	}
+	if len(results) == 0 {
+		lines = append(lines, "The store holds no documents.")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,256 @@
+package savecontext
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/source"
+	"docs4context-com/internal/store"
+)
+
+const refreshedContent = `TITLE: Routing
+DESCRIPTION: Routes requests
+SOURCE: https://example.com/routing
+LANGUAGE: go
+CODE:
+` + "```\nrouter.Get(\"/\", handler)\n```\n"
+
+// context7Server serves refreshedContent as context7 does, with an ETag, and
+// records the If-None-Match header of every request by its URL
+type context7Server struct {
+	*httptest.Server
+	mu          sync.Mutex
+	ifNoneMatch map[string]string
+	// unconditional makes the server ignore If-None-Match, as servers that
+	// do not support conditional requests do
+	unconditional bool
+}
+
+func newContext7Server(t *testing.T) *context7Server {
+	t.Helper()
+	srv := &context7Server{ifNoneMatch: make(map[string]string)}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		srv.mu.Lock()
+		srv.ifNoneMatch[r.URL.RequestURI()] = r.Header.Get("If-None-Match")
+		conditional := !srv.unconditional
+		srv.mu.Unlock()
+		if r.URL.Path != "/a/b/llms.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		if conditional && r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprint(w, refreshedContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// requests returns the URLs requested and the If-None-Match header sent with each
+func (srv *context7Server) requests() map[string]string {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	requests := make(map[string]string)
+	for uri, etag := range srv.ifNoneMatch {
+		requests[uri] = etag
+		delete(srv.ifNoneMatch, uri)
+	}
+	return requests
+}
+
+// configureStore points the stores at a temporary directory and the context7
+// source at baseURL, and returns the project store root
+func configureStore(t *testing.T, baseURL string) string {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "llm-context")
+	if err := store.Configure(store.Options{Root: root, GlobalRoot: filepath.Join(dir, "global"), ConfigPath: configPath, Context7URL: baseURL}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Configure(store.Options{ConfigPath: configPath})
+	})
+	return root
+}
+
+// storeDocument writes the document of a/b with a header recording source
+// and etag, checked a day ago, and returns its path
+func storeDocument(t *testing.T, root, source, etag string) string {
+	t.Helper()
+	path := filepath.Join(root, "a", "b", store.DocumentFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	header := fmt.Sprintf("# METADATA\n# TOKEN_COUNT: 20\n# DATE_CREATED: 2025-01-01T00:00:00Z\n# REPO: a/b\n# SOURCE: %s\n", source)
+	if etag != "" {
+		header += fmt.Sprintf("# ETAG: %s\n", etag)
+	}
+	if err := os.WriteFile(path, []byte(header+"#\n"+refreshedContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dayAgo := time.Now().Add(-24 * time.Hour)
+	if err := os.Chtimes(path, dayAgo, dayAgo); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// refreshOne refreshes the only stored document and returns its result
+func refreshOne(t *testing.T, root string) RefreshResult {
+	t.Helper()
+	results, err := Refresh(context.Background(), root, nil, 0, false, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("refresh results = %+v, want one", results)
+	}
+	return results[0]
+}
+
+func TestRefreshSendsValidatorsOnlyForRecordedURL(t *testing.T) {
+	srv := newContext7Server(t)
+	root := configureStore(t, srv.URL)
+	fullURL := fmt.Sprintf("/a/b/llms.txt?tokens=%d", source.DefaultTokenCount)
+
+	// The document was downloaded with a token limit that refresh raises, so
+	// the validators recorded for that URL are not sent with the new request
+	path := storeDocument(t, root, srv.URL+"/a/b/llms.txt?tokens=20", `"v1"`)
+	result := refreshOne(t, root)
+	if result.Status != RefreshUnchanged {
+		t.Errorf("refresh of a document saved with a lower limit = %+v, want unchanged", result)
+	}
+	if requests := srv.requests(); len(requests) != 1 || requests[fullURL] != "" {
+		t.Errorf("requests = %v, want %s without If-None-Match", requests, fullURL)
+	}
+	// The header now records the URL its validators belong to
+	doc, err := snippet.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Metadata.Source != srv.URL+fullURL || doc.Metadata.Fields["ETAG"] != `"v1"` {
+		t.Errorf("header after refresh: SOURCE %s, ETAG %s, want %s and \"v1\"", doc.Metadata.Source, doc.Metadata.Fields["ETAG"], srv.URL+fullURL)
+	}
+
+	// Requested again at the recorded URL, the validators are sent
+	storeDocument(t, root, srv.URL+fullURL, `"v1"`)
+	if result := refreshOne(t, root); result.Status != RefreshUnchanged {
+		t.Errorf("refresh of a document at its recorded URL = %+v, want unchanged", result)
+	}
+	if requests := srv.requests(); len(requests) != 1 || requests[fullURL] != `"v1"` {
+		t.Errorf("requests = %v, want %s with If-None-Match \"v1\"", requests, fullURL)
+	}
+}
+
+func TestContext7Request(t *testing.T) {
+	configureStore(t, "https://mirror.example.com/context7")
+	large := source.DefaultTokenCount * 2
+
+	tests := map[string]struct {
+		url        string
+		repo       string
+		tokenCount int
+		want       source.FetchOptions
+		ok         bool
+	}{
+		"default URL without a limit": {"https://context7.com/a/b/llms.txt", "a/b", 0, source.FetchOptions{MaxTokens: source.DefaultTokenCount}, true},
+		"mirror URL":                  {"https://mirror.example.com/context7/a/b/llms.txt", "a/b", 0, source.FetchOptions{MaxTokens: source.DefaultTokenCount}, true},
+		"version and topic":           {"https://context7.com/a/b/v1.2.0/llms.txt?topic=routing", "a/b", 0, source.FetchOptions{Version: "v1.2.0", Topic: "routing", MaxTokens: source.DefaultTokenCount}, true},
+		"escaped version":             {"https://context7.com/a/b/release%2F2/llms.txt", "a/b", 0, source.FetchOptions{Version: "release/2", MaxTokens: source.DefaultTokenCount}, true},
+		"low limit raised":            {"https://context7.com/a/b/llms.txt?tokens=500", "a/b", 0, source.FetchOptions{MaxTokens: source.DefaultTokenCount}, true},
+		"raised to the stored size":   {"https://context7.com/a/b/llms.txt?tokens=500", "a/b", large, source.FetchOptions{MaxTokens: large}, true},
+		"high limit kept":             {fmt.Sprintf("https://context7.com/a/b/llms.txt?tokens=%d", large), "a/b", 0, source.FetchOptions{MaxTokens: large}, true},
+		"limit of a tokens variant":   {"https://context7.com/a/b/llms.txt?topic=routing&tokens=500", "a/b@routing-tokens-500", large, source.FetchOptions{Topic: "routing", MaxTokens: 500}, true},
+		"another repository":          {"https://context7.com/a/c/llms.txt", "a/b", 0, source.FetchOptions{}, false},
+		"another host":                {"https://example.com/a/b/llms.txt", "a/b", 0, source.FetchOptions{}, false},
+		"outside the mirror path":     {"https://mirror.example.com/a/b/llms.txt", "a/b", 0, source.FetchOptions{}, false},
+		"another file":                {"https://context7.com/a/b/README.md", "a/b", 0, source.FetchOptions{}, false},
+		"unparsable URL":              {"https://context7.com/%zz", "a/b", 0, source.FetchOptions{}, false},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			repo, err := store.ParseRepo(tt.repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := context7Request(tt.url, repo, tt.tokenCount)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("context7Request(%s) = %+v, %v, want %+v, %v", tt.url, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRefreshTouchesUnchangedDocument(t *testing.T) {
+	tests := map[string]struct {
+		etag          string
+		unconditional bool
+		// rewritten is whether the header is rewritten to record new validators
+		rewritten bool
+	}{
+		"not modified":                  {etag: `"v1"`},
+		"identical content":             {etag: `"v1"`, unconditional: true},
+		"identical content, new ETag":   {etag: "", rewritten: true},
+		"identical content, other ETag": {etag: `"v0"`, rewritten: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv := newContext7Server(t)
+			srv.unconditional = tt.unconditional
+			root := configureStore(t, srv.URL)
+			path := storeDocument(t, root, fmt.Sprintf("%s/a/b/llms.txt?tokens=%d", srv.URL, source.DefaultTokenCount), tt.etag)
+			before, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now().Add(-time.Second)
+			if result := refreshOne(t, root); result.Status != RefreshUnchanged || result.AgeDays != 0 {
+				t.Fatalf("refresh = %+v, want unchanged and checked now", result)
+			}
+
+			after, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rewritten := string(after) != string(before); rewritten != tt.rewritten {
+				t.Errorf("document rewritten = %v, want %v:\n%s", rewritten, tt.rewritten, after)
+			}
+			doc := snippet.Parse(after)
+			if doc.Metadata.DateCreated != "2025-01-01T00:00:00Z" || doc.Metadata.Fields["ETAG"] != `"v1"` {
+				t.Errorf("header: DATE_CREATED %s, ETAG %s, want the original date and \"v1\"", doc.Metadata.DateCreated, doc.Metadata.Fields["ETAG"])
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ModTime().Before(start) {
+				t.Errorf("modification time %v, want the time of the check", info.ModTime())
+			}
+
+			// Checked just now, the document is fresh for a day
+			results, err := Refresh(context.Background(), root, nil, 1, false, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].Status != RefreshFresh {
+				t.Errorf("refresh after the check = %+v, want fresh", results)
+			}
+		})
+	}
+}
@@ -1,35 +1,62 @@
 package savecontext
 
 import (
+	"bytes"
 	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
 
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/snapshot"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/source"
+	"docs4context-com/internal/store"
+	"docs4context-com/internal/tokens"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
-	"github.com/pkoukk/tiktoken-go"
 )
 
-const defaultTokenCount = 100000000 // 100 million tokens
-
 // AddTool adds the document saving tool to the server
 func AddTool(s *server.MCPServer) {
 	saveContextTool := mcp.NewTool("save_context_document",
 		mcp.WithDescription("Download and save repository context document for LLM use from GitHub URL"),
 		mcp.WithString("github_url",
 			mcp.Required(),
-			mcp.Description("GitHub repository URL (e.g., https://github.com/nanostores/nanostores or nanostores/nanostores)"),
+			mcp.Description("GitHub repository URL (e.g., https://github.com/nanostores/nanostores or nanostores/nanostores), or the URL of an llms.txt or llms-full.txt document published by a project (e.g., https://svelte.dev/llms.txt)"),
+		),
+		mcp.WithString("repo",
+			mcp.Description("Repository (owner/name) to store a document URL under; derived from the URL by default"),
+		),
+		mcp.WithString("version",
+			mcp.Description("Version of the library to fetch the documentation of, e.g. 'v1.10.0', when the source provides versions"),
+		),
+		mcp.WithString("topic",
+			mcp.Description("Fetch only the snippets about a topic, e.g. 'routing', stored as a variant alongside the full document and addressed as owner/repo@topic"),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description("Fetch at most this many tokens of the document instead of all of it; the result is stored as a variant like 'topic'"),
+		),
+		mcp.WithString("scope",
+			mcp.Description("Store to save to: 'project' (default) for the project store, whose documents shadow global ones, or 'global' for the user-level store shared by all projects"),
+			mcp.Enum(store.ScopeProject, store.ScopeGlobal),
 		),
 		mcp.WithString("output_dir",
-			mcp.Description("Output directory for saving the context document (defaults to 'llm-context')"),
+			mcp.Description("Output directory for saving the context document, overriding 'scope'; documents saved elsewhere are still found by the search tools"),
+		),
+		mcp.WithString("source",
+			mcp.Description(fmt.Sprintf("Source to download the document from: one of %s (default '%s'); further sources can be defined in the config file", strings.Join(source.Names(), ", "), source.Default)),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
 		),
 	)
 
@@ -42,65 +69,239 @@ func AddTool(s *server.MCPServer) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		outputDir := "llm-context"
-		if outputDirParam := request.GetString("output_dir", ""); outputDirParam != "" {
-			outputDir = outputDirParam
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("SAVE_CONTEXT_DOCUMENT tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		// Parse GitHub URL to extract username and repository
-		username, repo, err := ParseGitHubURL(githubURL)
+		outputDir, err := ResolveOutputDir(request.GetString("output_dir", ""), request.GetString("scope", store.DefaultScope))
 		if err != nil {
-			log.Printf("SAVE_CONTEXT_DOCUMENT tool error - failed to parse GitHub URL: %v", err)
-			return mcp.NewToolResultError(fmt.Sprintf("failed to parse GitHub URL: %v", err)), nil
+			log.Printf("SAVE_CONTEXT_DOCUMENT tool error - invalid output location: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		log.Printf("Parsed GitHub URL: username=%s, repo=%s", username, repo)
-
-		// Try to download the document directly first (bypassing token count for now)
-		var tokenCount int
-		log.Printf("Attempting direct download without token count...")
-		content, err := downloadContextDocument(username, repo, 0)
+		result, err := Save(ctx, Request{
+			Target:    githubURL,
+			Repo:      request.GetString("repo", ""),
+			Source:    request.GetString("source", ""),
+			Version:   request.GetString("version", ""),
+			Topic:     request.GetString("topic", ""),
+			MaxTokens: request.GetInt("max_tokens", 0),
+			OutputDir: outputDir,
+		})
 		if err != nil {
-			log.Printf("Direct download failed: %v. Trying with token count...", err)
-			// If direct download fails, try to get token count first
-			tokenCount, tokenErr := fetchTokenCount(username, repo)
-			if tokenErr != nil {
-			log.Printf("SAVE_CONTEXT_DOCUMENT tool - failed to fetch exact token count, using %d tokens as fallback: %v (original error: %v)", defaultTokenCount, tokenErr, err)
-			tokenCount = defaultTokenCount			}
-
-			log.Printf("Token count retrieved: %d", tokenCount)
+			log.Printf("SAVE_CONTEXT_DOCUMENT tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		UpdateStore(outputDir)
 
-			// Download the context document with token count
-			content, err = downloadContextDocument(username, repo, tokenCount)
+		log.Printf("SAVE_CONTEXT_DOCUMENT tool: Successfully saved context document to %s (%d tokens)", result.Path, result.TokenCount)
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(result)
 			if err != nil {
-				log.Printf("SAVE_CONTEXT_DOCUMENT tool error - failed to download document: %v", err)
-				return mcp.NewToolResultError(fmt.Sprintf("failed to download document: %v", err)), nil
+				return mcp.NewToolResultError(err.Error()), nil
 			}
-		} else {
-			log.Printf("Direct download successful!")
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Successfully downloaded and saved context document to %s\nSource: %s (%s)\nTokens: %d\nSize: %d bytes", result.Path, result.Source, result.SourceURL, result.TokenCount, result.Size)), nil
+	})
+}
+
+// Request describes a document to download and save
+type Request struct {
+	// Target is a GitHub repository URL, "owner/repo", or the URL of a
+	// document published by a project
+	Target string
+	// Repo names the repository a document URL is stored under; it is
+	// derived from the URL when empty
+	Repo string
+	// Source names the source to download from; empty selects the default
+	Source string
+	// Version selects the documentation of a library version
+	Version string
+	// Topic and MaxTokens select part of the document, stored as a variant
+	Topic     string
+	MaxTokens int
+	// OutputDir is the directory to save to, as returned by ResolveOutputDir
+	OutputDir string
+}
+
+// Result describes a saved document
+type Result struct {
+	Repo       string `json:"repo"`
+	Store      string `json:"store"`
+	Source     string `json:"source"`
+	SourceURL  string `json:"source_url"`
+	Path       string `json:"path"`
+	TokenCount int    `json:"token_count"`
+	Size       int    `json:"size_bytes"`
+}
+
+// Save downloads the document described by req and writes it into
+// req.OutputDir with a metadata header. It leaves the search index alone, so
+// that several documents can be saved before one call to UpdateStore.
+func Save(ctx context.Context, req Request) (Result, error) {
+	repoID, src, err := resolve(req)
+	if err != nil {
+		return Result{}, err
+	}
+	opts := source.FetchOptions{
+		Version:   strings.TrimSpace(req.Version),
+		Topic:     strings.TrimSpace(req.Topic),
+		MaxTokens: req.MaxTokens,
+	}
+
+	// Try to download the document directly first, and fetch its metadata
+	// only when that fails and no token limit was given
+	tokenCount := opts.MaxTokens
+	log.Printf("Attempting direct download of %s from source %s...", repoID, src.Name())
+	document, err := src.FetchDocument(ctx, repoID, opts)
+	if err != nil {
+		if opts.MaxTokens > 0 {
+			return Result{}, fmt.Errorf("failed to download document: %v", err)
 		}
+		log.Printf("Direct download failed: %v. Trying with metadata...", err)
+		meta, metaErr := src.FetchMetadata(ctx, repoID)
+		if metaErr != nil || meta.TokenCount <= 0 {
+			log.Printf("Failed to fetch metadata of %s: %v", repoID, metaErr)
+			return Result{}, fmt.Errorf("failed to download document: %v", err)
+		}
+		tokenCount = meta.TokenCount
+
+		log.Printf("Token count retrieved: %d", tokenCount)
 
-		// Count tokens using tiktoken
-		actualTokenCount, err := countTokens(content)
+		// Download the context document with token count
+		opts.MaxTokens = tokenCount
+		document, err = src.FetchDocument(ctx, repoID, opts)
 		if err != nil {
-			log.Printf("SAVE_CONTEXT_DOCUMENT tool warning - failed to count tokens: %v, using default count", err)
-			actualTokenCount = tokenCount // Use the original count as fallback
+			return Result{}, fmt.Errorf("failed to download document: %v", err)
 		}
+	} else {
+		log.Printf("Direct download successful!")
+	}
+
+	content, err := normalize(document.Content, document.URL)
+	if err != nil {
+		return Result{}, err
+	}
 
-		// Save the document to the specified directory with metadata
-		outputPath := filepath.Join(outputDir, username, repo, "llms.txt")
-		err = saveDocument(outputPath, content, username, repo, actualTokenCount)
+	// Count tokens using tiktoken
+	actualTokenCount, err := countTokens(content)
+	if err != nil {
+		log.Printf("Failed to count tokens of %s: %v, using default count", repoID, err)
+		actualTokenCount = tokenCount // Use the original count as fallback
+		if actualTokenCount <= 0 || actualTokenCount == source.DefaultTokenCount {
+			actualTokenCount = tokens.Estimate(string(content))
+		}
+	}
+
+	// Save the document to the specified directory with metadata, making
+	// sure no symbolic link inside the store leads the write outside it
+	outputPath := repoID.File(req.OutputDir)
+	if err := store.CheckDir(filepath.Dir(outputPath)); err != nil {
+		return Result{}, fmt.Errorf("failed to save document: %v", err)
+	}
+	if err := saveDocument(outputPath, content, documentHeader{
+		Repo:         repoID,
+		TokenCount:   actualTokenCount,
+		Source:       document.URL,
+		Version:      opts.Version,
+		ETag:         document.ETag,
+		LastModified: document.LastModified,
+	}); err != nil {
+		return Result{}, fmt.Errorf("failed to save document: %v", err)
+	}
+
+	return Result{
+		Repo:       repoID.String(),
+		Store:      store.Scope(req.OutputDir),
+		Source:     src.Name(),
+		SourceURL:  document.URL,
+		Path:       outputPath,
+		TokenCount: actualTokenCount,
+		Size:       len(content),
+	}, nil
+}
+
+// TargetRepo returns the repository a request saves the document of,
+// including the variant selected by its topic and token limit
+func TargetRepo(req Request) (store.Repo, error) {
+	repoID, _, err := resolve(req)
+	return repoID, err
+}
+
+// resolve returns the repository and source of a request
+func resolve(req Request) (store.Repo, source.Source, error) {
+	// The URL of a document published by a project is fetched as is;
+	// anything else names a repository to look up in the source
+	var src source.Source
+	var repoID store.Repo
+	var err error
+	if documentURL, ok := DocumentURL(req.Target); ok {
+		if req.Source != "" {
+			return store.Repo{}, nil, fmt.Errorf("'source' cannot be combined with the URL of a document, which is fetched directly")
+		}
+		repoID, err = RepoForURL(documentURL, req.Repo)
+		if err != nil {
+			return store.Repo{}, nil, err
+		}
+		src = source.ForURL(documentURL)
+		log.Printf("Fetching document URL %s as %s", documentURL, repoID)
+	} else {
+		src, err = source.Get(req.Source)
 		if err != nil {
-			log.Printf("SAVE_CONTEXT_DOCUMENT tool error - failed to save document: %v", err)
-			return mcp.NewToolResultError(fmt.Sprintf("failed to save document: %v", err)), nil
+			return store.Repo{}, nil, err
 		}
 
-		log.Printf("SAVE_CONTEXT_DOCUMENT tool: Successfully saved context document to %s (%d tokens)", outputPath, actualTokenCount)
-		return mcp.NewToolResultText(fmt.Sprintf("Successfully downloaded and saved context document to %s\nTokens: %d\nSize: %d bytes", outputPath, actualTokenCount, len(content))), nil
-	})
+		// Parse GitHub URL to extract username and repository
+		username, repo, err := ParseGitHubURL(req.Target)
+		if err != nil {
+			return store.Repo{}, nil, fmt.Errorf("failed to parse GitHub URL: %v", err)
+		}
+
+		log.Printf("Parsed GitHub URL: username=%s, repo=%s", username, repo)
+		repoID = store.Repo{Owner: username, Name: repo}
+	}
+
+	// A topic or token limit selects part of the document, which is stored
+	// as a variant so it does not replace the full document
+	if req.MaxTokens < 0 {
+		return store.Repo{}, nil, fmt.Errorf("max_tokens must be a positive number")
+	}
+	if repoID.Variant == "" {
+		repoID.Variant = store.VariantName(strings.TrimSpace(req.Topic), req.MaxTokens)
+	}
+	return repoID, src, nil
 }
 
-// ParseGitHubURL extracts username and repository name from various GitHub URL formats
+// UpdateStore keeps the search index of outputDir in step with the documents
+// saved there, and lets searches find documents saved outside the store root
+func UpdateStore(outputDir string) {
+	if err := index.Update(outputDir); err != nil {
+		log.Printf("Warning - failed to update search index of %s: %v", outputDir, err)
+	}
+	if err := store.Register(outputDir); err != nil {
+		log.Printf("Warning - failed to register output directory %s: %v", outputDir, err)
+	}
+}
+
+// ResolveOutputDir returns the directory to save documents to: outputDir
+// when it is set, which must lie inside the stores, or else the root of
+// the store for scope
+func ResolveOutputDir(outputDir, scope string) (string, error) {
+	if outputDir == "" {
+		return store.RootFor(scope)
+	}
+	if err := store.CheckDir(outputDir); err != nil {
+		return "", fmt.Errorf("invalid output_dir: %v", err)
+	}
+	return outputDir, nil
+}
+
+// ParseGitHubURL extracts username and repository name from various GitHub
+// URL formats. Both are validated with store.ParseRepo, so they are safe to
+// use as path elements.
 func ParseGitHubURL(url string) (username, repo string, err error) {
 	// Remove any trailing slashes
 	url = strings.TrimSuffix(url, "/")
@@ -110,151 +311,164 @@ func ParseGitHubURL(url string) (username, repo string, err error) {
 		// Full GitHub URL: https://github.com/username/repo
 		parts := strings.Split(strings.TrimPrefix(url, "https://github.com/"), "/")
 		if len(parts) >= 2 {
-			return parts[0], parts[1], nil
+			return validateRepo(parts[0], parts[1])
 		}
 	} else if strings.Count(url, "/") == 1 && !strings.Contains(url, "://") {
 		// Short format: username/repo
 		parts := strings.Split(url, "/")
 		if len(parts) == 2 {
-			return parts[0], parts[1], nil
+			return validateRepo(parts[0], parts[1])
 		}
 	}
 
 	return "", "", fmt.Errorf("invalid GitHub URL format. Expected: https://github.com/username/repo or username/repo")
 }
 
-// countTokens counts the number of tokens in the given content using tiktoken
-func countTokens(content []byte) (int, error) {
-	// Use cl100k_base encoding (used by GPT-4, GPT-3.5-turbo)
-	encoding, err := tiktoken.GetEncoding("cl100k_base")
+// validateRepo checks the username and repository taken from a URL
+func validateRepo(username, repo string) (string, string, error) {
+	parsed, err := store.ParseRepo(username + "/" + repo)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get encoding: %v", err)
+		return "", "", err
 	}
-
-	// Count tokens
-	tokens := encoding.Encode(string(content), nil, nil)
-	return len(tokens), nil
+	return parsed.Owner, parsed.Name, nil
 }
 
-// fetchTokenCount retrieves the token count from context7.com
-func fetchTokenCount(username, repo string) (int, error) {
-	url := fmt.Sprintf("https://context7.com/%s/%s", username, repo)
-	log.Printf("Fetching token count from: %s", url)
-
-	// Create a client with a User-Agent header to avoid being blocked
-	client := &http.Client{
-		Timeout: 30 * time.Second,
+// DocumentURL reports whether target is the URL of a document rather than
+// of a repository, returning the URL to download. GitHub links to a file,
+// such as https://github.com/owner/repo/blob/main/llms.txt, are rewritten to
+// the raw file; links to the repository itself are not document URLs.
+func DocumentURL(target string) (string, bool) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", false
 	}
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return 0, fmt.Errorf("failed to create request: %v", err)
+	if parsed.Host != "github.com" && parsed.Host != "www.github.com" {
+		return parsed.String(), true
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
-	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return 0, fmt.Errorf("failed to fetch page: %v", err)
+	// github.com/owner/repo/blob/ref/path or .../raw/ref/path
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if len(parts) < 5 || (parts[2] != "blob" && parts[2] != "raw") {
+		return "", false
 	}
-	defer resp.Body.Close()
+	raw := url.URL{Scheme: "https", Host: "raw.githubusercontent.com", Path: "/" + path.Join(append(parts[:2:2], parts[3:]...)...)}
+	return raw.String(), true
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("failed to fetch page, status: %d", resp.StatusCode)
+// RepoForURL returns the repository to store a document URL under: repo when
+// it is given, the owner and repository of a raw GitHub file, or else the
+// host and the path of the document, e.g. svelte.dev/llms-full for
+// https://svelte.dev/llms-full.txt
+func RepoForURL(documentURL, repo string) (store.Repo, error) {
+	if repo != "" {
+		return store.ParseRepo(repo)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	parsed, err := url.Parse(documentURL)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read response body: %v", err)
+		return store.Repo{}, fmt.Errorf("invalid URL %q: %v", documentURL, err)
 	}
-
-	// Extract token count using multiple regex patterns
-	// First try: HTML structure with spans containing "Tokens:" followed by the number
-	// Pattern matches: <span>Tokens:</span><span>66,551</span>
-	tokenRegex := regexp.MustCompile(`<span[^>]*>Tokens:</span><span[^>]*>([0-9,]+)</span>`)
-	matches := tokenRegex.FindStringSubmatch(string(body))
-
-	if len(matches) < 2 {
-		// Second try: Look for "Tokens:" with optional whitespace and number in JSON or text
-		tokenRegex = regexp.MustCompile(`"?Tokens"?\s*:?\s*"?([0-9,]+)"?`)
-		matches = tokenRegex.FindStringSubmatch(string(body))
-	}
-
-	if len(matches) < 2 {
-		// Third try: More flexible pattern for Tokens followed by number
-		tokenRegex = regexp.MustCompile(`(?i)tokens[:\s]*([0-9,]+)`)
-		matches = tokenRegex.FindStringSubmatch(string(body))
+	parts := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+	if parsed.Host == "raw.githubusercontent.com" && len(parts) >= 2 {
+		return store.ParseRepo(parts[0] + "/" + parts[1])
 	}
 
-	if len(matches) < 2 {
-		// Final fallback: Look for any sequence that might contain token info
-		preview := string(body)
-		if len(preview) > 1000 {
-			preview = preview[:1000]
-		}
-		log.Printf("Debug: Page content preview: %s", preview)
-		log.Printf("Token count not found in page content, will use %d as fallback", defaultTokenCount)
-		return defaultTokenCount, nil
+	owner := strings.TrimPrefix(parsed.Hostname(), "www.")
+	name := strings.TrimSuffix(strings.TrimSuffix(strings.Trim(parsed.Path, "/"), ".txt"), ".md")
+	if name == "" {
+		name = "llms"
 	}
-
-	// Remove commas from the number and convert to int
-	tokenStr := strings.ReplaceAll(matches[1], ",", "")
-	tokenCount, err := strconv.Atoi(tokenStr)
+	derived, err := store.ParseRepo(sanitizeName(owner) + "/" + sanitizeName(name))
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse token count: %v", err)
+		return store.Repo{}, fmt.Errorf("cannot derive a repository from %s, pass 'repo' as owner/name: %v", documentURL, err)
 	}
-
-	return tokenCount, nil
+	return derived, nil
 }
 
-// downloadContextDocument downloads the llms.txt file with the specified token count
-func downloadContextDocument(username, repo string, tokenCount int) ([]byte, error) {
-	var url string
-	if tokenCount > 0 {
-		url = fmt.Sprintf("https://context7.com/%s/%s/llms.txt?tokens=%d", username, repo, tokenCount)
-	} else {
-		url = fmt.Sprintf("https://context7.com/%s/%s/llms.txt?tokens=%d", username, repo, defaultTokenCount)
-	}
-	log.Printf("Downloading context document from: %s", url)
+// sanitizeName replaces the characters a repository identifier part cannot
+// hold with '-' and trims those it cannot start with
+func sanitizeName(part string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.':
+			return r
+		}
+		return '-'
+	}, part)
+	return strings.TrimLeft(sanitized, "-_.")
+}
 
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, fmt.Errorf("failed to download document: %v", err)
+// normalize returns a downloaded document in the snippet format. Documents
+// following the llms.txt markdown convention are converted to the snippet
+// format the search tools understand.
+func normalize(content []byte, documentURL string) ([]byte, error) {
+	if err := snippet.Parse(content).Validate(); err == nil {
+		return content, nil
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("failed to download document, status: %d", resp.StatusCode)
+	converted := snippet.FromMarkdown(content, documentURL)
+	if err := snippet.Parse(converted).Validate(); err != nil {
+		return nil, fmt.Errorf("%s is neither in the snippet format nor llms.txt markdown: %v", documentURL, err)
 	}
+	log.Printf("Converted llms.txt markdown from %s to the snippet format", documentURL)
+	return converted, nil
+}
 
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read document content: %v", err)
-	}
+// countTokens counts the number of tokens in the given content using tiktoken
+func countTokens(content []byte) (int, error) {
+	return tokens.Count(string(content))
+}
 
-	return content, nil
+// documentHeader holds the values written to the metadata header
+type documentHeader struct {
+	Repo       store.Repo
+	TokenCount int
+	Source     string
+	// Version is the version of the library the document describes, if any
+	Version string
+	// ETag and LastModified are the validators of the download, if any,
+	// sent back on refresh to skip unchanged documents
+	ETag         string
+	LastModified string
+	// DateCreated is when the content was downloaded; empty means now
+	DateCreated string
 }
 
 // saveDocument saves the downloaded content to the specified path with metadata header
-func saveDocument(outputPath string, content []byte, username, repo string, tokenCount int) error {
+func saveDocument(outputPath string, content []byte, meta documentHeader) error {
 	// Create the directory structure if it doesn't exist
 	dir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return fmt.Errorf("failed to create directory %s: %v", dir, err)
 	}
 
+	// Keep the version being replaced as a snapshot when the content changed
+	if previous, err := os.ReadFile(outputPath); err == nil && !bytes.Equal(stripHeader(previous), content) {
+		if err := snapshot.Take(outputPath); err != nil {
+			log.Printf("Warning - failed to keep a snapshot of %s: %v", outputPath, err)
+		}
+	}
+
 	// Generate metadata header
-	currentTime := time.Now().UTC().Format(time.RFC3339)
-	sourceURL := fmt.Sprintf("https://context7.com/%s/%s/llms.txt", username, repo)
-	
+	currentTime := meta.DateCreated
+	if currentTime == "" {
+		currentTime = time.Now().UTC().Format(time.RFC3339)
+	}
 	header := fmt.Sprintf(`# METADATA
 # TOKEN_COUNT: %d
 # DATE_CREATED: %s
-# REPO: %s/%s
+# REPO: %s
 # SOURCE: %s
-#
-`, tokenCount, currentTime, username, repo, sourceURL)
+`, meta.TokenCount, currentTime, meta.Repo, meta.Source)
+	if meta.Version != "" {
+		header += fmt.Sprintf("# VERSION: %s\n", meta.Version)
+	}
+	if meta.ETag != "" {
+		header += fmt.Sprintf("# ETAG: %s\n", meta.ETag)
+	}
+	if meta.LastModified != "" {
+		header += fmt.Sprintf("# LAST_MODIFIED: %s\n", meta.LastModified)
+	}
+	header += "#\n"
 
 	// Combine header with content
 	finalContent := []byte(header)
@@ -266,4 +480,4 @@ func saveDocument(outputPath string, content []byte, username, repo string, toke
 	}
 
 	return nil
-}
\ No newline at end of file
+}
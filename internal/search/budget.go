@@ -0,0 +1,109 @@
+package search
+
+import (
+	"fmt"
+	"log"
+
+	"docs4context-com/internal/tokens"
+)
+
+// tokenBudget packs rendered result blocks into a maximum number of tokens.
+// Results are offered best first; once a block does not fit it and every
+// later block are dropped, so the next page can resume at the first of them.
+type tokenBudget struct {
+	max     int
+	used    int
+	packed  int
+	dropped int
+	// exceeded is set when a result was included over the budget
+	exceeded bool
+	// estimated is set once the tiktoken encoding failed to load, after
+	// which token counts are approximated
+	estimated bool
+}
+
+// newTokenBudget returns a budget of max tokens; zero or less means unlimited
+func newTokenBudget(max int) *tokenBudget {
+	return &tokenBudget{max: max}
+}
+
+// count returns the number of tokens in text
+func (b *tokenBudget) count(text string) int {
+	if !b.estimated {
+		count, err := tokens.Count(text)
+		if err == nil {
+			return count
+		}
+		log.Printf("Token budget: %v, estimating token counts instead", err)
+		b.estimated = true
+	}
+	return tokens.Estimate(text)
+}
+
+// reserve charges text that is always included, such as headers
+func (b *tokenBudget) reserve(text string) {
+	if b.max > 0 {
+		b.used += b.count(text)
+	}
+}
+
+// fit reports whether block fits in the remaining budget, charging it if so
+// and counting it as dropped otherwise
+func (b *tokenBudget) fit(block string) bool {
+	if b.max <= 0 {
+		b.packed++
+		return true
+	}
+	if b.dropped > 0 {
+		b.dropped++
+		return false
+	}
+	cost := b.count(block)
+	if b.used+cost > b.max {
+		b.dropped++
+		return false
+	}
+	b.used += cost
+	b.packed++
+	return true
+}
+
+// force charges block whether or not it fits, for a result that must be
+// included for the output to make progress
+func (b *tokenBudget) force(block string) {
+	b.packed++
+	if b.max <= 0 {
+		return
+	}
+	b.used += b.count(block)
+	if b.used > b.max {
+		b.exceeded = true
+	}
+}
+
+// info describes how the budget was used for JSON output, or nil when no
+// budget was set
+func (b *tokenBudget) info() *budgetInfo {
+	if b.max <= 0 {
+		return nil
+	}
+	return &budgetInfo{MaxTokens: b.max, TokensUsed: b.used, Estimated: b.estimated, Dropped: b.dropped, Exceeded: b.exceeded}
+}
+
+// summary describes how the budget was used, or "" when no budget was set
+func (b *tokenBudget) summary() string {
+	if b.max <= 0 {
+		return ""
+	}
+	approx := ""
+	if b.estimated {
+		approx = "~"
+	}
+	if b.exceeded {
+		return fmt.Sprintf("Token budget: used %s%d of %d tokens; the first result alone exceeds the budget and was included anyway (raise 'max_tokens' to page through larger results)", approx, b.used, b.max)
+	}
+	if b.dropped == 0 {
+		return fmt.Sprintf("Token budget: used %s%d of %d tokens", approx, b.used, b.max)
+	}
+	return fmt.Sprintf("Token budget: used %s%d of %d tokens; dropped %d results that did not fit (raise 'max_tokens', narrow the query or continue with the cursor)", approx, b.used, b.max, b.dropped)
+}
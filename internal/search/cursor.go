@@ -0,0 +1,160 @@
+package search
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+)
+
+// cursorParam documents the cursor parameter for tool descriptions
+const cursorParam = "Opaque cursor from a previous call with the same query and filters, to continue with the next page of results"
+
+// pager selects one page of ranked results. Results are identified by their
+// position in the ranking, which is deterministic for a given query and set
+// of stored documents, so a cursor resumes exactly where the last page ended.
+type pager struct {
+	// key identifies the query and filters the cursor is valid for, and
+	// state the stored documents ranked
+	key    string
+	state  string
+	offset int
+	limit  int
+	budget *tokenBudget
+	shown  int
+	// format is the output format; items collects the results for JSON output
+	format string
+	items  []any
+}
+
+// newPager starts the page selected by opts.cursor. The key parts identify
+// the search mode and query; the repository and language filters and the
+// snapshot searched are added so a cursor cannot be replayed against a
+// different result set, and the fingerprint of ix so it is rejected once the
+// stored documents change.
+func newPager(opts searchOptions, ix *index.Index, key ...string) (*pager, error) {
+	p := &pager{
+		key:    strings.Join(append(key, opts.repoFilter, opts.language, opts.snapshot), "\x00"),
+		state:  ix.Fingerprint(),
+		limit:  opts.limit,
+		budget: newTokenBudget(opts.maxTokens),
+		format: opts.format,
+		items:  []any{},
+	}
+
+	if opts.cursor != "" {
+		offset, err := decodeCursor(opts.cursor, p.key, p.state)
+		if err != nil {
+			return nil, err
+		}
+		p.offset = offset
+	}
+
+	return p, nil
+}
+
+// inPage reports whether the result at position i of the ranking is on this page
+func (p *pager) inPage(i int) bool {
+	return i >= p.offset && (p.limit <= 0 || i < p.offset+p.limit)
+}
+
+// reserve charges a text header against the token budget
+func (p *pager) reserve(header string) {
+	if p.format != format.JSON {
+		p.budget.reserve(header)
+	}
+}
+
+// add appends the text block of a result to results, and its JSON form item
+// to the page items, when the result fits in the token budget. The first
+// result of a page is always added, so the next cursor always advances.
+func (p *pager) add(results, block []string, item any) []string {
+	rendered := renderBlock(p.format, block, item)
+	if p.shown == 0 {
+		p.budget.force(rendered)
+	} else if !p.budget.fit(rendered) {
+		return results
+	}
+	p.shown++
+	p.items = append(p.items, item)
+	return append(results, block...)
+}
+
+// info describes the page out of total results for JSON output
+func (p *pager) info(total int) pageInfo {
+	info := pageInfo{
+		Total:    total,
+		Offset:   p.offset,
+		Returned: p.shown,
+	}
+	if end := p.offset + p.shown; p.shown > 0 && end < total {
+		info.NextCursor = encodeCursor(p.key, p.state, end)
+	}
+	info.Budget = p.budget.info()
+	return info
+}
+
+// summary describes the page out of total results of the given kind, and
+// returns the cursor of the next page when there is one
+func (p *pager) summary(total int, kind string) []string {
+	var summary []string
+	end := p.offset + p.shown
+
+	switch {
+	case p.offset == 0 && end >= total:
+		summary = append(summary, fmt.Sprintf("Showing all %d matching %s", total, kind))
+	case p.shown == 0:
+		summary = append(summary, fmt.Sprintf("No %s on this page (%d matching)", kind, total))
+	default:
+		summary = append(summary, fmt.Sprintf("Showing %s %d-%d of %d matching", kind, p.offset+1, end, total))
+	}
+	if line := p.budget.summary(); line != "" {
+		summary = append(summary, line)
+	}
+	if p.shown > 0 && end < total {
+		summary = append(summary, fmt.Sprintf("More results available: call again with cursor \"%s\" for the next page", encodeCursor(p.key, p.state, end)))
+	}
+
+	return summary
+}
+
+// encodeCursor returns an opaque cursor for the result at offset of the
+// ranking identified by key, over the stored documents identified by state
+func encodeCursor(key, state string, offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset) + ":" + cursorKey(key) + ":" + state))
+}
+
+// decodeCursor returns the offset stored in cursor, checking that the cursor
+// was issued for the ranking identified by key and state
+func decodeCursor(cursor, key, state string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	offset, err := strconv.Atoi(parts[0])
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	if parts[1] != cursorKey(key) {
+		return 0, fmt.Errorf("cursor belongs to a different query or filters; omit it to start from the first page")
+	}
+	if parts[2] != state {
+		return 0, fmt.Errorf("cursor is out of date because stored documents changed since it was issued; omit it to start from the first page")
+	}
+	return offset, nil
+}
+
+// cursorKey shortens a ranking key to the hash stored in cursors
+func cursorKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
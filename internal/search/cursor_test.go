@@ -0,0 +1,111 @@
+package search
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"docs4context-com/internal/format"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	for _, offset := range []int{0, 1, 20, 12345} {
+		cursor := encodeCursor("titles\x00query", "state", offset)
+		got, err := decodeCursor(cursor, "titles\x00query", "state")
+		if err != nil || got != offset {
+			t.Errorf("decodeCursor(encodeCursor(%d)) = %d, %v", offset, got, err)
+		}
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	const key, state = "titles\x00query", "state"
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	valid := encodeCursor(key, state, 5)
+
+	invalid := map[string]string{
+		"not base64":        "!!!",
+		"padded base64":     base64.URLEncoding.EncodeToString([]byte("5:" + cursorKey(key) + ":" + state)),
+		"empty":             encode(""),
+		"offset only":       encode("5"),
+		"missing state":     encode("5:" + cursorKey(key)),
+		"extra part":        encode("5:" + cursorKey(key) + ":" + state + ":x"),
+		"non-numeric":       encode("five:" + cursorKey(key) + ":" + state),
+		"negative offset":   encode("-1:" + cursorKey(key) + ":" + state),
+		"overflowing":       encode("99999999999999999999:" + cursorKey(key) + ":" + state),
+		"truncated":         valid[:len(valid)-2],
+		"tampered key hash": encode("5:000000000000:" + state),
+	}
+	for name, cursor := range invalid {
+		if offset, err := decodeCursor(cursor, key, state); err == nil {
+			t.Errorf("%s: decodeCursor(%q) = %d, want an error", name, cursor, offset)
+		}
+	}
+
+	if _, err := decodeCursor(valid, "content\x00query", state); err == nil || !strings.Contains(err.Error(), "different query") {
+		t.Errorf("cursor for another query: error = %v, want a different query error", err)
+	}
+	if _, err := decodeCursor(valid, key, "changed"); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("cursor for other documents: error = %v, want an out of date error", err)
+	}
+}
+
+func TestSearchTitlesRejectsStaleCursor(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "a", "b", "llms.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	var blocks []string
+	for i := 1; i <= 3; i++ {
+		blocks = append(blocks, fmt.Sprintf("TITLE: Routing %d\nDESCRIPTION: d\nSOURCE: s%d\n", i, i))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(blocks, "----------------------------------------\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := searchOptions{roots: []string{root}, limit: 1, format: format.JSON}
+	search := func(cursor string) (searchResponse, error) {
+		opts.cursor = cursor
+		text, err := searchTitles("routing", opts)
+		if err != nil {
+			return searchResponse{}, err
+		}
+		var response searchResponse
+		if err := json.Unmarshal([]byte(text), &response); err != nil {
+			t.Fatalf("search result is not JSON: %v\n%s", err, text)
+		}
+		return response, nil
+	}
+
+	first, err := search("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Page.NextCursor == "" {
+		t.Fatalf("first page = %+v, want a next cursor", first.Page)
+	}
+	second, err := search(first.Page.NextCursor)
+	if err != nil || second.Page.Offset != 1 {
+		t.Fatalf("second page = %+v, %v, want offset 1", second.Page, err)
+	}
+
+	// Rewriting the document invalidates cursors issued before
+	if err := os.WriteFile(path, []byte(strings.Join(blocks[1:], "----------------------------------------\n")), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := search(first.Page.NextCursor); err == nil || !strings.Contains(err.Error(), "out of date") {
+		t.Errorf("search with a cursor issued before the documents changed: error = %v, want an out of date error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/snippet"
+)
+
+// defaultSimilarity is the fuzzy match threshold used when none is given
+const defaultSimilarity = 0.75
+
+// fuzzyTitleSearch finds topics whose titles contain a word similar to every
+// word of the query, tolerating typos such as "Recovry" or "middlewear"
+func fuzzyTitleSearch(query string, threshold float64, opts searchOptions) (string, error) {
+	// Check if any context documents are stored
+	roots := opts.roots
+	if len(roots) == 0 {
+		return message(opts.format, noDocumentsMessage)
+	}
+
+	if threshold <= 0 || threshold > 1 {
+		return "", fmt.Errorf("similarity must be greater than 0 and at most 1, got %g", threshold)
+	}
+
+	words := index.Tokenize(query)
+	if len(words) == 0 {
+		return "", fmt.Errorf("fuzzy search needs a query containing letters or digits")
+	}
+
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
+
+	type fuzzyHit struct {
+		ref        index.Ref
+		similarity float64
+		terms      []string
+	}
+
+	// Every query word must have a similar title word
+	var hits map[index.Ref]*fuzzyHit
+	for _, word := range words {
+		matches := ix.FuzzyLookup(word, snippet.MaskTitle, threshold)
+		next := make(map[index.Ref]*fuzzyHit)
+		for ref, match := range matches {
+			hit, ok := hits[ref]
+			if hits != nil && !ok {
+				continue
+			}
+			if !ok {
+				hit = &fuzzyHit{ref: ref}
+			}
+			hit.similarity += match.Similarity
+			hit.terms = append(hit.terms, match.Term)
+			next[ref] = hit
+		}
+		hits = next
+	}
+
+	var languageRefs map[index.Ref]bool
+	if opts.language != "" {
+		languageRefs = ix.Language(opts.language)
+	}
+
+	var ranked []*fuzzyHit
+	for _, hit := range hits {
+		if opts.repoFilter != "" && ix.Documents[hit.ref.Doc].Repo != opts.repoFilter {
+			continue
+		}
+		if languageRefs != nil && !languageRefs[hit.ref] {
+			continue
+		}
+		hit.similarity /= float64(len(words))
+		ranked = append(ranked, hit)
+	}
+	sort.Slice(ranked, func(a, b int) bool {
+		if ranked[a].similarity != ranked[b].similarity {
+			return ranked[a].similarity > ranked[b].similarity
+		}
+		if ranked[a].ref.Doc != ranked[b].ref.Doc {
+			return ranked[a].ref.Doc < ranked[b].ref.Doc
+		}
+		return ranked[a].ref.Snippet < ranked[b].ref.Snippet
+	})
+
+	var results []string
+	results = append(results, fmt.Sprintf("=== Fuzzy Search Results for Title Query: '%s' (similarity >= %.2f) ===\n", query, threshold))
+
+	page, err := newPager(opts, ix, "fuzzy", query, strconv.FormatFloat(threshold, 'g', -1, 64))
+	if err != nil {
+		return "", err
+	}
+	page.reserve(results[0])
+
+	for i, hit := range ranked {
+		if !page.inPage(i) {
+			continue
+		}
+		snip := ix.Snippet(hit.ref)
+		block := []string{
+			fmt.Sprintf("\n--- %s (similarity: %.2f, matched: %s) ---", ix.Documents[hit.ref.Doc].Repo, hit.similarity, strings.Join(hit.terms, ", ")),
+			fmt.Sprintf("Line %d: TITLE: %s", snip.TitleLine, snip.Title),
+		}
+		if snip.DescriptionLine != 0 {
+			block = append(block, fmt.Sprintf("Line %d: DESCRIPTION: %s", snip.DescriptionLine, strings.SplitN(snip.Description, "\n", 2)[0]))
+		}
+		topic := indexedTopic(ix.Documents[hit.ref.Doc].Repo, snip)
+		topic.Similarity = hit.similarity
+		topic.MatchedTerms = hit.terms
+		results = page.add(results, block, topic)
+	}
+
+	if opts.format == format.JSON {
+		return format.Marshal(searchResponse{Query: query, Mode: "fuzzy", Results: page.items, Page: page.info(len(ranked))})
+	}
+
+	if len(ranked) == 0 {
+		results = append(results, "\nNo matching titles found. Try lowering 'similarity'.")
+	} else {
+		results = append(results, "")
+		results = append(results, page.summary(len(ranked), "topics")...)
+	}
+
+	return strings.Join(results, "\n"), nil
+}
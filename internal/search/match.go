@@ -0,0 +1,169 @@
+package search
+
+import (
+	"fmt"
+	"log"
+
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/query"
+	"docs4context-com/internal/snippet"
+)
+
+// querySyntax documents the query language for tool descriptions
+const querySyntax = "Words must all match (AND); also supports OR, NOT or -word, \"quoted phrases\", parentheses and the prefixes title:, desc:, code: and lang: (e.g. 'server \"stdio transport\" lang:go -deprecated')"
+
+// parseQuery parses a tool query, wrapping syntax errors for the caller
+func parseQuery(input string) (*query.Node, error) {
+	node, err := query.Parse(input)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %v", err)
+	}
+	return node, nil
+}
+
+// candidates uses the index to narrow down the snippets that can match node.
+// The result is a superset of the matches; all is true when the index cannot
+// narrow the query, e.g. for negations or terms without letters or digits.
+func candidates(ix *index.Index, node *query.Node, defaults snippet.FieldMask) (refs map[index.Ref]bool, all bool) {
+	switch node.Op {
+	case query.OpTerm:
+		if node.Term.Language {
+			return ix.Language(node.Term.Text), false
+		}
+		fields := node.Term.Fields
+		if fields == 0 {
+			fields = defaults
+		}
+		refs, ok := ix.Lookup(node.Term.Text, fields)
+		return refs, !ok
+	case query.OpAnd:
+		all = true
+		for _, child := range node.Children {
+			childRefs, childAll := candidates(ix, child, defaults)
+			if childAll {
+				continue
+			}
+			if all {
+				refs, all = childRefs, false
+				continue
+			}
+			for ref := range refs {
+				if !childRefs[ref] {
+					delete(refs, ref)
+				}
+			}
+		}
+		return refs, all
+	case query.OpOr:
+		refs = make(map[index.Ref]bool)
+		for _, child := range node.Children {
+			childRefs, childAll := candidates(ix, child, defaults)
+			if childAll {
+				return nil, true
+			}
+			for ref := range childRefs {
+				refs[ref] = true
+			}
+		}
+		return refs, false
+	}
+	return nil, true
+}
+
+// rankSnippets returns the snippets that may match node, best first. Terms
+// without a field prefix are scored over scoreFields, which lets titles
+// contribute to the ranking of content searches.
+func rankSnippets(ix *index.Index, node *query.Node, defaults, scoreFields snippet.FieldMask, repoFilter string) []index.Hit {
+	var refs []index.Ref
+	if candidateRefs, all := candidates(ix, node, defaults); all {
+		refs = ix.All(repoFilter)
+	} else {
+		for ref := range candidateRefs {
+			if repoFilter == "" || ix.Documents[ref.Doc].Repo == repoFilter {
+				refs = append(refs, ref)
+			}
+		}
+	}
+
+	var scoreTerms []index.ScoreTerm
+	for _, term := range node.Terms() {
+		fields := term.Fields
+		if fields == 0 {
+			fields = scoreFields
+		}
+		scoreTerms = append(scoreTerms, index.ScoreTerm{Text: term.Text, Fields: fields})
+	}
+	scorer := ix.NewScorer(scoreTerms)
+
+	hits := make([]index.Hit, 0, len(refs))
+	for _, ref := range refs {
+		hits = append(hits, index.Hit{Ref: ref, Score: scorer.Score(ref)})
+	}
+	index.SortHits(hits)
+
+	return hits
+}
+
+// documentCache parses each stored document at most once per search
+type documentCache struct {
+	ix   *index.Index
+	docs map[int]*snippet.Document
+}
+
+func newDocumentCache(ix *index.Index) *documentCache {
+	return &documentCache{ix: ix, docs: make(map[int]*snippet.Document)}
+}
+
+// snippet returns the parsed document and snippet for ref, or nil when the
+// document cannot be read or no longer matches the index
+func (c *documentCache) snippet(ref index.Ref) (*snippet.Document, *snippet.Snippet) {
+	doc, ok := c.docs[ref.Doc]
+	if !ok {
+		path := c.ix.Documents[ref.Doc].File()
+		var err error
+		doc, err = snippet.ParseFile(path)
+		if err != nil {
+			log.Printf("Failed to read file %s: %v", path, err)
+		}
+		c.docs[ref.Doc] = doc
+	}
+	if doc == nil || ref.Snippet >= len(doc.Snippets) {
+		return nil, nil
+	}
+	return doc, &doc.Snippets[ref.Snippet]
+}
+
+// withLanguage restricts node to snippets with an example in language
+func withLanguage(node *query.Node, language string) *query.Node {
+	if language == "" {
+		return node
+	}
+	return &query.Node{Op: query.OpAnd, Children: []*query.Node{node, query.Language(language)}}
+}
+
+// inLanguage reports whether a line should be considered under a language
+// filter: code lines must belong to an example in that language
+func inLanguage(snip *snippet.Snippet, line snippet.Line, language string) bool {
+	if language == "" || line.Field != snippet.FieldCode {
+		return true
+	}
+	return snippet.NormalizeLanguage(snip.Examples[line.Example].Language) == snippet.NormalizeLanguage(language)
+}
+
+// matchingLines returns the lines of the snippet containing any of the terms,
+// skipping code in other languages when a language filter is set
+func matchingLines(snip *snippet.Snippet, terms []query.Term, defaults snippet.FieldMask, language string) []snippet.Line {
+	var lines []snippet.Line
+	for _, line := range snip.Lines() {
+		if !inLanguage(snip, line, language) {
+			continue
+		}
+		for _, term := range terms {
+			if term.MatchText(line.Text, line.Field, defaults) {
+				lines = append(lines, line)
+				break
+			}
+		}
+	}
+	return lines
+}
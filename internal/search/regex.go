@@ -0,0 +1,181 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/query"
+	"docs4context-com/internal/snippet"
+)
+
+// Limits applied to agent supplied regular expressions. Go's RE2 engine runs
+// in linear time, so these bound memory use and the total scan time rather
+// than guarding against catastrophic backtracking.
+const (
+	maxPatternLength = 1000
+	maxProgramSize   = 20000
+	regexTimeout     = 10 * time.Second
+	// maxSpansPerLine caps the spans reported for a single line
+	maxSpansPerLine = 10
+)
+
+// compileRegex compiles an agent supplied pattern, rejecting patterns that are
+// too long or that compile to an excessively large program
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) > maxPatternLength {
+		return nil, fmt.Errorf("regular expression is too long (%d characters, maximum %d)", len(pattern), maxPatternLength)
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	prog, err := syntax.Compile(parsed.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	if len(prog.Inst) > maxProgramSize {
+		return nil, fmt.Errorf("regular expression is too complex (%d instructions, maximum %d)", len(prog.Inst), maxProgramSize)
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %v", err)
+	}
+	return re, nil
+}
+
+// regexSearch matches pattern against the selected fields of every stored
+// snippet, line by line, and reports the matching spans in file order
+func regexSearch(ctx context.Context, pattern string, fields snippet.FieldMask, opts searchOptions) (string, error) {
+	// Check if any context documents are stored
+	roots := opts.roots
+	if len(roots) == 0 {
+		return message(opts.format, noDocumentsMessage)
+	}
+
+	re, err := compileRegex(pattern)
+	if err != nil {
+		return "", err
+	}
+
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, regexTimeout)
+	defer cancel()
+
+	var results []string
+	results = append(results, fmt.Sprintf("=== Regex Search Results for '%s' in %s ===\n", pattern, describeFields(fields)))
+
+	page, err := newPager(opts, ix, "regex", pattern, describeFields(fields))
+	if err != nil {
+		return "", err
+	}
+	page.reserve(results[0])
+
+	matchedTopics := 0
+	var languageFilter *query.Node
+	if opts.language != "" {
+		languageFilter = query.Language(opts.language)
+	}
+
+	err = walkDocuments(ix, opts.repoFilter, func(stored *index.Document, doc *snippet.Document) error {
+		for i := range doc.Snippets {
+			snip := &doc.Snippets[i]
+			if languageFilter != nil && !languageFilter.Match(snip, fields) {
+				continue
+			}
+
+			var topicResults []string
+			var lineMatches []lineMatch
+			for _, line := range snip.Lines() {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if fields&line.Field.Mask() == 0 || !inLanguage(snip, line, opts.language) {
+					continue
+				}
+				spans := re.FindAllStringIndex(line.Text, maxSpansPerLine)
+				if len(spans) == 0 {
+					continue
+				}
+
+				// Columns count characters of the raw line, which includes the
+				// "TITLE: " style prefix
+				raw := doc.Line(line.Number)
+				offset := strings.Index(raw, line.Text)
+				if offset < 0 {
+					offset = 0
+				}
+
+				topicResults = append(topicResults, fmt.Sprintf("Line %d: %s", line.Number, raw))
+				match := lineMatch{Line: line.Number, Field: line.Field.String(), Text: line.Text}
+				for _, span := range spans {
+					start := utf8.RuneCountInString(raw[:offset+span[0]]) + 1
+					end := utf8.RuneCountInString(raw[:offset+span[1]])
+					topicResults = append(topicResults, fmt.Sprintf("  match at columns %d-%d: %s", start, end, line.Text[span[0]:span[1]]))
+					match.Spans = append(match.Spans, matchSpan{Start: start, End: end, Text: line.Text[span[0]:span[1]]})
+				}
+				lineMatches = append(lineMatches, match)
+			}
+			if len(topicResults) == 0 {
+				continue
+			}
+
+			matchedTopics++
+			if !page.inPage(matchedTopics - 1) {
+				continue
+			}
+			block := append([]string{fmt.Sprintf("\n--- %s: %s (line %d) ---", stored.Repo, snip.Title, snip.StartLine)}, topicResults...)
+			topic := parsedTopic(stored.Repo, snip, false)
+			topic.Matches = lineMatches
+			results = page.add(results, block, topic)
+		}
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("regex search timed out after %s", regexTimeout)
+		}
+		return "", fmt.Errorf("failed to search files: %v", err)
+	}
+
+	if opts.format == format.JSON {
+		return format.Marshal(searchResponse{Query: pattern, Mode: "regex", Results: page.items, Page: page.info(matchedTopics)})
+	}
+
+	if matchedTopics == 0 {
+		results = append(results, "\nNo matches found.")
+	} else {
+		results = append(results, "")
+		results = append(results, page.summary(matchedTopics, "topics")...)
+	}
+
+	return strings.Join(results, "\n"), nil
+}
+
+// describeFields names the fields of a mask for result headers
+func describeFields(fields snippet.FieldMask) string {
+	var names []string
+	for field := snippet.FieldTitle; field <= snippet.FieldCode; field++ {
+		if fields&field.Mask() == 0 {
+			continue
+		}
+		if field == snippet.FieldCode {
+			names = append(names, "code")
+		} else {
+			names = append(names, field.String()+"s")
+		}
+	}
+	return strings.Join(names, " and ")
+}
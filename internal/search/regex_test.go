@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/snippet"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// regexStore writes a document with non-ASCII titles and returns its root
+func regexStore(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	path := filepath.Join(root, "a", "b", "llms.txt")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := "TITLE: Größe ändern\nDESCRIPTION: d\nSOURCE: s1\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestRegexSearchReportsCharacterColumns(t *testing.T) {
+	opts := searchOptions{roots: []string{regexStore(t)}, format: format.JSON}
+	text, err := regexSearch(context.Background(), "ändern", snippet.MaskTitle, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var response struct {
+		Results []struct {
+			Matches []lineMatch `json:"matches"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal([]byte(text), &response); err != nil {
+		t.Fatalf("regex result is not JSON: %v\n%s", err, text)
+	}
+	if len(response.Results) != 1 || len(response.Results[0].Matches) != 1 || len(response.Results[0].Matches[0].Spans) != 1 {
+		t.Fatalf("regex results = %s, want one span", text)
+	}
+	// "TITLE: Größe " is 13 characters but 15 bytes long
+	if span := response.Results[0].Matches[0].Spans[0]; span.Start != 14 || span.End != 19 || span.Text != "ändern" {
+		t.Errorf("span = %+v, want columns 14-19", span)
+	}
+
+	opts.format = format.Text
+	if text, err := regexSearch(context.Background(), "ändern", snippet.MaskTitle, opts); err != nil || !strings.Contains(text, "match at columns 14-19") {
+		t.Errorf("regex text result = %q, %v, want columns 14-19", text, err)
+	}
+}
+
+func TestRegexSearchStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	opts := searchOptions{roots: []string{regexStore(t)}, format: format.Text}
+	if _, err := regexSearch(ctx, "x", snippet.MaskTitle, opts); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("regex search with a canceled context: error = %v, want a timeout", err)
+	}
+}
+
+func TestSearchTitlesRejectsRegexWithFuzzy(t *testing.T) {
+	s := server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	AddSearchTitles(s)
+
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params": map[string]any{
+			"name":      "search_titles",
+			"arguments": map[string]any{"query": "x", "regex": true, "fuzzy": true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, ok := s.HandleMessage(context.Background(), message).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatal("search_titles returned no result")
+	}
+	result, ok := response.Result.(mcp.CallToolResult)
+	if !ok || !result.IsError {
+		t.Errorf("search_titles with regex and fuzzy = %+v, want a tool error", response.Result)
+	}
+}
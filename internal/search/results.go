@@ -0,0 +1,173 @@
+package search
+
+import (
+	"encoding/json"
+	"strings"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/snippet"
+)
+
+// noDocumentsMessage is returned when nothing has been downloaded yet
+const noDocumentsMessage = "No context documents found. Please download some repositories first using save_context_document."
+
+// searchResponse is the JSON form of a title or content search
+type searchResponse struct {
+	Query   string   `json:"query"`
+	Mode    string   `json:"mode"`
+	Results []any    `json:"results"`
+	Page    pageInfo `json:"page"`
+}
+
+// pageInfo describes the page of results returned and how to get the next one
+type pageInfo struct {
+	Total      int         `json:"total"`
+	Offset     int         `json:"offset"`
+	Returned   int         `json:"returned"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Budget     *budgetInfo `json:"budget,omitempty"`
+}
+
+// budgetInfo describes how a max_tokens budget was used
+type budgetInfo struct {
+	MaxTokens  int  `json:"max_tokens"`
+	TokensUsed int  `json:"tokens_used"`
+	Estimated  bool `json:"estimated,omitempty"`
+	Dropped    int  `json:"dropped"`
+	// Exceeded is set when the first result of a page did not fit the budget
+	// and was included anyway
+	Exceeded bool `json:"exceeded,omitempty"`
+}
+
+// repositoriesResponse is the JSON form of list_repositories
+type repositoriesResponse struct {
+	Repositories []any       `json:"repositories"`
+	Budget       *budgetInfo `json:"budget,omitempty"`
+}
+
+// keywordResponse is the JSON form of analyze_keywords
+type keywordResponse struct {
+	Keyword      string   `json:"keyword"`
+	TotalMatches int      `json:"total_matches"`
+	Repositories []any    `json:"repositories"`
+	Page         pageInfo `json:"page"`
+}
+
+// topicDetailsResponse is the JSON form of get_topic_details
+type topicDetailsResponse struct {
+	Repo    string      `json:"repo"`
+	Details []any       `json:"details"`
+	Budget  *budgetInfo `json:"budget,omitempty"`
+}
+
+// topicDetail describes one requested line: the topic starting there, the
+// lines around it, a metadata header line or a line out of range
+type topicDetail struct {
+	Line  int            `json:"line"`
+	Kind  string         `json:"kind"`
+	Topic *topicResult   `json:"topic,omitempty"`
+	Lines []numberedLine `json:"lines,omitempty"`
+}
+
+// numberedLine is a raw line of a stored document
+type numberedLine struct {
+	Line int    `json:"line"`
+	Text string `json:"text"`
+}
+
+// topicResult is the JSON form of a matching snippet
+type topicResult struct {
+	Repo            string          `json:"repo"`
+	Title           string          `json:"title"`
+	TitleLine       int             `json:"title_line"`
+	Description     string          `json:"description,omitempty"`
+	DescriptionLine int             `json:"description_line,omitempty"`
+	Source          string          `json:"source,omitempty"`
+	StartLine       int             `json:"start_line"`
+	EndLine         int             `json:"end_line"`
+	Languages       []string        `json:"languages,omitempty"`
+	Examples        []exampleResult `json:"examples,omitempty"`
+	Score           float64         `json:"score,omitempty"`
+	Similarity      float64         `json:"similarity,omitempty"`
+	MatchedTerms    []string        `json:"matched_terms,omitempty"`
+	Matches         []lineMatch     `json:"matches,omitempty"`
+}
+
+// exampleResult is the JSON form of a code example
+type exampleResult struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+// lineMatch is a matching line of a snippet
+type lineMatch struct {
+	Line  int         `json:"line"`
+	Field string      `json:"field"`
+	Text  string      `json:"text"`
+	Spans []matchSpan `json:"spans,omitempty"`
+}
+
+// matchSpan is a regex match on a line, as 1-based inclusive columns of the
+// raw line counted in characters
+type matchSpan struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
+
+// indexedTopic converts an indexed snippet to its JSON form
+func indexedTopic(repo string, entry *index.Snippet) topicResult {
+	return topicResult{
+		Repo:            repo,
+		Title:           entry.Title,
+		TitleLine:       entry.TitleLine,
+		Description:     entry.Description,
+		DescriptionLine: entry.DescriptionLine,
+		StartLine:       entry.StartLine,
+		EndLine:         entry.EndLine,
+		Languages:       entry.Languages,
+	}
+}
+
+// parsedTopic converts a parsed snippet to its JSON form, optionally with
+// the code of its examples
+func parsedTopic(repo string, snip *snippet.Snippet, withExamples bool) topicResult {
+	topic := topicResult{
+		Repo:            repo,
+		Title:           snip.Title,
+		TitleLine:       snip.TitleLine,
+		Description:     snip.Description,
+		DescriptionLine: snip.DescriptionLine,
+		Source:          snip.Source,
+		StartLine:       snip.StartLine,
+		EndLine:         snip.EndLine,
+		Languages:       snip.Languages(),
+	}
+	if withExamples {
+		for _, example := range snip.Examples {
+			topic.Examples = append(topic.Examples, exampleResult{Language: example.Language, Code: example.Code})
+		}
+	}
+	return topic
+}
+
+// renderBlock returns the text charged against a token budget for a result:
+// the rendered text lines, or the JSON encoding of item in JSON mode
+func renderBlock(outputFormat string, block []string, item any) string {
+	if outputFormat == format.JSON {
+		data, err := json.Marshal(item)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return strings.Join(block, "\n")
+}
+
+// message returns a plain message, wrapped in an object in JSON mode
+func message(outputFormat, text string) (string, error) {
+	if outputFormat == format.JSON {
+		return format.Marshal(map[string]string{"message": text})
+	}
+	return text, nil
+}
@@ -9,21 +9,115 @@ import (
 	"strconv"
 	"strings"
 
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/snapshot"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// defaultLimit is the number of ranked topics returned when no limit is given
+const defaultLimit = 20
+
+// searchOptions holds the parameters shared by the search tools
+type searchOptions struct {
+	repoFilter string
+	// roots are the store roots searched, or the directories of the
+	// snapshot with ID snapshot when it is set
+	roots    []string
+	snapshot string
+	// language restricts results to snippets with an example in this language
+	language string
+	// limit is the page size and cursor the position of the page
+	limit  int
+	cursor string
+	// maxTokens bounds the size of the output; zero means unbounded
+	maxTokens int
+	// format is the output format, format.Text or format.JSON
+	format string
+}
+
+// searchOptionsFromRequest reads the shared search parameters of a tool call
+func searchOptionsFromRequest(request mcp.CallToolRequest) (searchOptions, error) {
+	outputFormat, err := format.FromRequest(request)
+	if err != nil {
+		return searchOptions{}, err
+	}
+
+	opts := searchOptions{
+		repoFilter: request.GetString("repo_filter", ""),
+		language:   request.GetString("language", ""),
+		cursor:     request.GetString("cursor", ""),
+		maxTokens:  request.GetInt("max_tokens", 0),
+		format:     outputFormat,
+		snapshot:   request.GetString("snapshot", ""),
+	}
+
+	opts.roots = store.Roots()
+	if opts.snapshot != "" {
+		if opts.roots, err = snapshot.Roots(opts.snapshot); err != nil {
+			return searchOptions{}, err
+		}
+	}
+
+	// With a token budget the budget decides how many results fit
+	limit := defaultLimit
+	if opts.maxTokens > 0 {
+		limit = 0
+	}
+	opts.limit = request.GetInt("limit", limit)
+
+	return opts, nil
+}
+
+// maxTokensParam documents the max_tokens parameter for tool descriptions
+const maxTokensParam = "Maximum number of tokens of output; the best results are packed until the budget is used up and the number of dropped results is reported"
+
+// languageParam documents the language parameter for tool descriptions
+const languageParam = "Only include topics with a code example in this LANGUAGE (e.g. 'go', 'typescript', 'bash'); common aliases such as 'golang' or 'ts' are accepted"
+
 // AddSearchTitles adds the search titles tool to the server
 func AddSearchTitles(s *server.MCPServer) {
 	searchTool := mcp.NewTool("search_titles",
 		mcp.WithDescription("Search for topics by title keywords across downloaded repository context documents"),
 		mcp.WithString("query",
 			mcp.Required(),
-			mcp.Description("Search query to match against topic titles"),
+			mcp.Description("Search query to match against topic titles. "+querySyntax),
 		),
 		mcp.WithString("repo_filter",
 			mcp.Description("Optional repository filter in format 'username/repo' to limit search scope"),
 		),
+		mcp.WithString("snapshot",
+			mcp.Description(snapshot.Param),
+		),
+		mcp.WithString("language",
+			mcp.Description(languageParam),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of topics per page, best matches first (defaults to %d)", defaultLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description(cursorParam),
+		),
+		mcp.WithBoolean("regex",
+			mcp.Description("Treat the query as a case-sensitive Go regular expression matched line by line (use (?i) for case-insensitive); reports match spans with line and column numbers"),
+		),
+		mcp.WithBoolean("fuzzy",
+			mcp.Description("Tolerate typos: every query word must be similar to a word of the title, by edit distance; cannot be combined with regex"),
+		),
+		mcp.WithNumber("similarity",
+			mcp.Description(fmt.Sprintf("Minimum similarity between 0 and 1 for fuzzy matches (defaults to %.2f); lower values tolerate more typos", defaultSimilarity)),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description(maxTokensParam),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
 	)
 
 	s.AddTool(searchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -35,95 +129,111 @@ func AddSearchTitles(s *server.MCPServer) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		repoFilter := request.GetString("repo_filter", "")
+		opts, err := searchOptionsFromRequest(request)
+		if err != nil {
+			log.Printf("SEARCH_TITLES tool error - invalid parameters: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		useRegex, useFuzzy := request.GetBool("regex", false), request.GetBool("fuzzy", false)
+		if useRegex && useFuzzy {
+			log.Printf("SEARCH_TITLES tool error - invalid parameters: regex and fuzzy both set")
+			return mcp.NewToolResultError("regex and fuzzy cannot be combined: set only one of them"), nil
+		}
 
-		results, err := searchTitles(query, repoFilter)
+		var results string
+		if useRegex {
+			results, err = regexSearch(ctx, query, snippet.MaskTitle, opts)
+		} else if useFuzzy {
+			results, err = fuzzyTitleSearch(query, request.GetFloat("similarity", defaultSimilarity), opts)
+		} else {
+			results, err = searchTitles(query, opts)
+		}
 		if err != nil {
 			log.Printf("SEARCH_TITLES tool error - search failed: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
 		}
 
 		log.Printf("SEARCH_TITLES tool: Found %d results for query '%s'", len(results), query)
-		return mcp.NewToolResultText(results), nil
+		return format.Result(opts.format, results), nil
 	})
 }
 
-// searchTitles searches for topics by title keywords
-func searchTitles(query, repoFilter string) (string, error) {
-	contextDir := "llm-context"
-	
-	// Check if context directory exists
-	if _, err := os.Stat(contextDir); os.IsNotExist(err) {
-		return "No context documents found. Please download some repositories first using save_context_document.", nil
+// searchTitles searches for topics by title keywords, best matches first
+func searchTitles(query string, opts searchOptions) (string, error) {
+	// Check if any context documents are stored
+	roots := opts.roots
+	if len(roots) == 0 {
+		return message(opts.format, noDocumentsMessage)
 	}
 
 	var results []string
 	results = append(results, fmt.Sprintf("=== Search Results for Title Query: '%s' ===\n", query))
 
-	err := filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
 
-		// Only process llms.txt files
-		if info.Name() != "llms.txt" {
-			return nil
-		}
+	node, err := parseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	node = withLanguage(node, opts.language)
 
-		// Extract repo info from path
-		relPath, err := filepath.Rel(contextDir, path)
-		if err != nil {
-			return err
-		}
-		pathParts := strings.Split(relPath, string(os.PathSeparator))
-		if len(pathParts) < 3 {
-			return nil
-		}
-		repoName := pathParts[0] + "/" + pathParts[1]
+	// Queries on titles, descriptions and languages are answered from the
+	// index alone; code terms need the stored document
+	var docs *documentCache
+	if node.UsesFields(snippet.MaskCode, snippet.MaskTitle) {
+		docs = newDocumentCache(ix)
+	}
 
-		// Apply repository filter if specified
-		if repoFilter != "" && repoName != repoFilter {
-			return nil
+	var hits []index.Hit
+	for _, hit := range rankSnippets(ix, node, snippet.MaskTitle, snippet.MaskTitle, opts.repoFilter) {
+		snip := indexedSnippet(ix.Snippet(hit.Ref))
+		if docs != nil {
+			if _, snip = docs.snippet(hit.Ref); snip == nil {
+				continue
+			}
 		}
-
-		// Search within this file
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Failed to read file %s: %v", path, err)
-			return nil
+		if snip.TitleLine != 0 && node.Match(snip, snippet.MaskTitle) {
+			hits = append(hits, hit)
 		}
+	}
 
-		lines := strings.Split(string(content), "\n")
-		foundMatches := false
-
-		for i, line := range lines {
-			// Skip metadata header lines
-			if strings.HasPrefix(line, "#") {
-				continue
-			}
+	page, err := newPager(opts, ix, "titles", query)
+	if err != nil {
+		return "", err
+	}
+	page.reserve(results[0])
 
-			if strings.HasPrefix(line, "TITLE:") && strings.Contains(strings.ToLower(line), strings.ToLower(query)) {
-				if !foundMatches {
-					results = append(results, fmt.Sprintf("\n--- %s ---", repoName))
-					foundMatches = true
-				}
-				results = append(results, fmt.Sprintf("Line %d: %s", i+1, line))
-				// Also include the next line if it's a description
-				if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "DESCRIPTION:") {
-					results = append(results, fmt.Sprintf("Line %d: %s", i+2, lines[i+1]))
-				}
-			}
+	for i, hit := range hits {
+		if !page.inPage(i) {
+			continue
 		}
+		snip := ix.Snippet(hit.Ref)
+		block := []string{
+			fmt.Sprintf("\n--- %s (score: %.2f) ---", ix.Documents[hit.Doc].Repo, hit.Score),
+			fmt.Sprintf("Line %d: TITLE: %s", snip.TitleLine, snip.Title),
+		}
+		// Also include the description
+		if snip.DescriptionLine != 0 {
+			block = append(block, fmt.Sprintf("Line %d: DESCRIPTION: %s", snip.DescriptionLine, strings.SplitN(snip.Description, "\n", 2)[0]))
+		}
+		topic := indexedTopic(ix.Documents[hit.Doc].Repo, snip)
+		topic.Score = hit.Score
+		results = page.add(results, block, topic)
+	}
 
-		return nil
-	})
-
-	if err != nil {
-		return "", fmt.Errorf("failed to search files: %v", err)
+	if opts.format == format.JSON {
+		return format.Marshal(searchResponse{Query: query, Mode: "titles", Results: page.items, Page: page.info(len(hits))})
 	}
 
-	if len(results) == 1 {
+	if len(hits) == 0 {
 		results = append(results, "\nNo matching titles found.")
+	} else {
+		results = append(results, "")
+		results = append(results, page.summary(len(hits), "topics")...)
 	}
 
 	return strings.Join(results, "\n"), nil
@@ -135,11 +245,33 @@ func AddSearchContent(s *server.MCPServer) {
 		mcp.WithDescription("Search across descriptions and code content in downloaded repository context documents"),
 		mcp.WithString("query",
 			mcp.Required(),
-			mcp.Description("Search query to match against descriptions and code content"),
+			mcp.Description("Search query to match against descriptions and code content. "+querySyntax),
 		),
 		mcp.WithString("repo_filter",
 			mcp.Description("Optional repository filter in format 'username/repo' to limit search scope"),
 		),
+		mcp.WithString("snapshot",
+			mcp.Description(snapshot.Param),
+		),
+		mcp.WithString("language",
+			mcp.Description(languageParam),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of topics per page, best matches first (defaults to %d)", defaultLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description(cursorParam),
+		),
+		mcp.WithBoolean("regex",
+			mcp.Description("Treat the query as a case-sensitive Go regular expression matched line by line (use (?i) for case-insensitive); reports match spans with line and column numbers"),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description(maxTokensParam),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
 	)
 
 	s.AddTool(searchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -151,118 +283,116 @@ func AddSearchContent(s *server.MCPServer) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		repoFilter := request.GetString("repo_filter", "")
+		opts, err := searchOptionsFromRequest(request)
+		if err != nil {
+			log.Printf("SEARCH_CONTENT tool error - invalid parameters: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-		results, err := searchContent(query, repoFilter)
+		var results string
+		if request.GetBool("regex", false) {
+			results, err = regexSearch(ctx, query, snippet.MaskDescription|snippet.MaskCode, opts)
+		} else {
+			results, err = searchContent(query, opts)
+		}
 		if err != nil {
 			log.Printf("SEARCH_CONTENT tool error - search failed: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("search failed: %v", err)), nil
 		}
 
 		log.Printf("SEARCH_CONTENT tool: Found results for query '%s'", query)
-		return mcp.NewToolResultText(results), nil
+		return format.Result(opts.format, results), nil
 	})
 }
 
-// searchContent searches across descriptions and code content
-func searchContent(query, repoFilter string) (string, error) {
-	contextDir := "llm-context"
-	
-	// Check if context directory exists
-	if _, err := os.Stat(contextDir); os.IsNotExist(err) {
-		return "No context documents found. Please download some repositories first using save_context_document.", nil
+// searchContent searches across descriptions and code content, returning the
+// best matching topics first
+func searchContent(query string, opts searchOptions) (string, error) {
+	// Check if any context documents are stored
+	roots := opts.roots
+	if len(roots) == 0 {
+		return message(opts.format, noDocumentsMessage)
 	}
 
 	var results []string
 	results = append(results, fmt.Sprintf("=== Search Results for Content Query: '%s' ===\n", query))
 
-	err := filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
 
-		// Only process llms.txt files
-		if info.Name() != "llms.txt" {
-			return nil
-		}
+	node, err := parseQuery(query)
+	if err != nil {
+		return "", err
+	}
 
-		// Extract repo info from path
-		relPath, err := filepath.Rel(contextDir, path)
-		if err != nil {
-			return err
-		}
-		pathParts := strings.Split(relPath, string(os.PathSeparator))
-		if len(pathParts) < 3 {
-			return nil
-		}
-		repoName := pathParts[0] + "/" + pathParts[1]
+	node = withLanguage(node, opts.language)
 
-		// Apply repository filter if specified
-		if repoFilter != "" && repoName != repoFilter {
-			return nil
-		}
+	defaults := snippet.MaskDescription | snippet.MaskCode
+	terms := node.Terms()
+	docs := newDocumentCache(ix)
 
-		// Search within this file
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Failed to read file %s: %v", path, err)
-			return nil
-		}
+	type contentMatch struct {
+		hit   index.Hit
+		doc   *snippet.Document
+		lines []snippet.Line
+	}
+	var matches []contentMatch
 
-		lines := strings.Split(string(content), "\n")
-		foundMatches := false
-		queryLower := strings.ToLower(query)
+	// Titles contribute to the score, but unprefixed terms only match descriptions and code
+	for _, hit := range rankSnippets(ix, node, defaults, snippet.MaskAll, opts.repoFilter) {
+		doc, snip := docs.snippet(hit.Ref)
+		if snip == nil || !node.Match(snip, defaults) {
+			continue
+		}
+		matches = append(matches, contentMatch{hit: hit, doc: doc, lines: matchingLines(snip, terms, defaults, opts.language)})
+	}
 
-		for i, line := range lines {
-			// Skip metadata header lines
-			if strings.HasPrefix(line, "#") {
-				continue
-			}
+	page, err := newPager(opts, ix, "content", query)
+	if err != nil {
+		return "", err
+	}
+	page.reserve(results[0])
 
-			lineLower := strings.ToLower(line)
-			
-			// Search in DESCRIPTION lines and CODE blocks
-			if (strings.HasPrefix(line, "DESCRIPTION:") || strings.HasPrefix(line, "CODE:") || 
-				(!strings.HasPrefix(line, "TITLE:") && !strings.HasPrefix(line, "SOURCE:") && 
-				 !strings.HasPrefix(line, "LANGUAGE:") && !strings.Contains(line, "----------------------------------------"))) &&
-				strings.Contains(lineLower, queryLower) {
-				
-				if !foundMatches {
-					results = append(results, fmt.Sprintf("\n--- %s ---", repoName))
-					foundMatches = true
-				}
-				
-				// Include some context around the match
-				contextStart := i - 2
-				if contextStart < 0 {
-					contextStart = 0
-				}
-				contextEnd := i + 2
-				if contextEnd >= len(lines) {
-					contextEnd = len(lines) - 1
+	for i, match := range matches {
+		if !page.inPage(i) {
+			continue
+		}
+		snip := &match.doc.Snippets[match.hit.Snippet]
+		block := []string{fmt.Sprintf("\n--- %s: %s (line %d, score: %.2f) ---", ix.Documents[match.hit.Doc].Repo, snip.Title, snip.StartLine, match.hit.Score)}
+		topic := parsedTopic(ix.Documents[match.hit.Doc].Repo, snip, false)
+		topic.Score = match.hit.Score
+
+		for _, line := range match.lines {
+			topic.Matches = append(topic.Matches, lineMatch{Line: line.Number, Field: line.Field.String(), Text: line.Text})
+
+			// Include some context around the match
+			block = append(block, fmt.Sprintf("Match at line %d:", line.Number))
+			for j := line.Number - 2; j <= line.Number+2; j++ {
+				if j < 1 || j > len(match.doc.Lines) {
+					continue
 				}
-				
-				results = append(results, fmt.Sprintf("Match at line %d:", i+1))
-				for j := contextStart; j <= contextEnd; j++ {
-					prefix := "  "
-					if j == i {
-						prefix = "* " // Mark the matching line
-					}
-					results = append(results, fmt.Sprintf("%s%s", prefix, lines[j]))
+				prefix := "  "
+				if j == line.Number {
+					prefix = "* " // Mark the matching line
 				}
-				results = append(results, "")
+				block = append(block, fmt.Sprintf("%s%s", prefix, match.doc.Line(j)))
 			}
+			block = append(block, "")
 		}
 
-		return nil
-	})
+		results = page.add(results, block, topic)
+	}
 
-	if err != nil {
-		return "", fmt.Errorf("failed to search files: %v", err)
+	if opts.format == format.JSON {
+		return format.Marshal(searchResponse{Query: query, Mode: "content", Results: page.items, Page: page.info(len(matches))})
 	}
 
-	if len(results) == 1 {
+	if len(matches) == 0 {
 		results = append(results, "\nNo matching content found.")
+	} else {
+		results = append(results, page.summary(len(matches), "topics")...)
 	}
 
 	return strings.Join(results, "\n"), nil
@@ -274,12 +404,22 @@ func AddGetTopicDetails(s *server.MCPServer) {
 		mcp.WithDescription("Extract complete topic information with context from specific line numbers in repository documents"),
 		mcp.WithString("repo",
 			mcp.Required(),
-			mcp.Description("Repository in format 'username/repo'"),
+			mcp.Description("Repository in format 'username/repo', or 'username/repo@variant' for a topic variant"),
 		),
 		mcp.WithString("line_numbers",
 			mcp.Required(),
 			mcp.Description("Comma-separated line numbers to extract topics from (e.g., '45,123,200')"),
 		),
+		mcp.WithString("snapshot",
+			mcp.Description("Read the topics from the snapshot with this ID, as searched with the search tools' snapshot parameter"),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description(maxTokensParam),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
 	)
 
 	s.AddTool(detailsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
@@ -297,25 +437,43 @@ func AddGetTopicDetails(s *server.MCPServer) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		results, err := getTopicDetails(repo, lineNumbersStr)
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("GET_TOPIC_DETAILS tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		roots := store.Roots()
+		if id := request.GetString("snapshot", ""); id != "" {
+			if roots, err = snapshot.Roots(id); err != nil {
+				log.Printf("GET_TOPIC_DETAILS tool error - invalid parameter 'snapshot': %v", err)
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		results, err := getTopicDetails(repo, lineNumbersStr, roots, request.GetInt("max_tokens", 0), outputFormat)
 		if err != nil {
 			log.Printf("GET_TOPIC_DETAILS tool error - extraction failed: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("extraction failed: %v", err)), nil
 		}
 
 		log.Printf("GET_TOPIC_DETAILS tool: Extracted details for repo '%s'", repo)
-		return mcp.NewToolResultText(results), nil
+		return format.Result(outputFormat, results), nil
 	})
 }
 
-// getTopicDetails extracts complete topic information from specific line numbers
-func getTopicDetails(repo, lineNumbersStr string) (string, error) {
-	contextDir := "llm-context"
-	filePath := filepath.Join(contextDir, repo, "llms.txt")
-	
+// getTopicDetails extracts complete topic information from specific line
+// numbers of the document of repo in roots
+func getTopicDetails(repo, lineNumbersStr string, roots []string, maxTokens int, outputFormat string) (string, error) {
+	repoID, err := store.ParseRepo(repo)
+	if err != nil {
+		return "", err
+	}
+
 	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Sprintf("Repository '%s' not found. Please download it first using save_context_document.", repo), nil
+	filePath, ok := documentPath(repoID, roots)
+	if !ok {
+		return message(outputFormat, fmt.Sprintf("Repository '%s' not found. Please download it first using save_context_document.", repo))
 	}
 
 	// Parse line numbers
@@ -337,75 +495,87 @@ func getTopicDetails(repo, lineNumbersStr string) (string, error) {
 		return "", fmt.Errorf("no valid line numbers provided")
 	}
 
-	// Read file content
-	content, err := os.ReadFile(filePath)
+	doc, err := snippet.ParseFile(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to read file: %v", err)
+		return "", err
 	}
 
-	lines := strings.Split(string(content), "\n")
 	var results []string
 	results = append(results, fmt.Sprintf("=== Topic Details for %s ===\n", repo))
 
-	for _, lineNum := range lineNumbers {
-		if lineNum < 1 || lineNum > len(lines) {
-			results = append(results, fmt.Sprintf("Line %d: OUT OF RANGE (file has %d lines)", lineNum, len(lines)))
-			continue
-		}
-
-		// Convert to 0-based index
-		lineIndex := lineNum - 1
-		line := lines[lineIndex]
-
-		// Skip metadata header lines
-		if strings.HasPrefix(line, "#") {
-			results = append(results, fmt.Sprintf("Line %d: METADATA HEADER - %s", lineNum, line))
-			continue
-		}
+	budget := newTokenBudget(maxTokens)
+	if outputFormat != format.JSON {
+		budget.reserve(results[0])
+	}
+	details := []any{}
 
-		// Find the complete topic block starting from this line
-		if strings.HasPrefix(line, "TITLE:") {
-			results = append(results, fmt.Sprintf("\n--- Topic starting at line %d ---", lineNum))
-			
-			// Extract the complete topic block
-			topicLines := []string{fmt.Sprintf("Line %d: %s", lineNum, line)}
-			
-			// Look for DESCRIPTION, SOURCE, LANGUAGE, and CODE
-			currentIndex := lineIndex + 1
-			for currentIndex < len(lines) && !strings.Contains(lines[currentIndex], "----------------------------------------") {
-				currentLine := lines[currentIndex]
-				if strings.HasPrefix(currentLine, "DESCRIPTION:") ||
-				   strings.HasPrefix(currentLine, "SOURCE:") ||
-				   strings.HasPrefix(currentLine, "LANGUAGE:") ||
-				   strings.HasPrefix(currentLine, "CODE:") ||
-				   (!strings.HasPrefix(currentLine, "TITLE:") && currentLine != "") {
-					topicLines = append(topicLines, fmt.Sprintf("Line %d: %s", currentIndex+1, currentLine))
+	for _, lineNum := range lineNumbers {
+		var block []string
+		detail := topicDetail{Line: lineNum}
+		if lineNum < 1 || lineNum > len(doc.Lines) {
+			block = append(block, fmt.Sprintf("Line %d: OUT OF RANGE (file has %d lines)", lineNum, len(doc.Lines)))
+			detail.Kind = "out_of_range"
+		} else if lineNum <= doc.Metadata.HeaderLines {
+			// Skip metadata header lines
+			block = append(block, fmt.Sprintf("Line %d: METADATA HEADER - %s", lineNum, doc.Line(lineNum)))
+			detail.Kind = "metadata"
+			detail.Lines = []numberedLine{{Line: lineNum, Text: doc.Line(lineNum)}}
+		} else if snip, ok := doc.SnippetAt(lineNum); ok && snip.StartLine == lineNum {
+			// Output the complete topic block when the line starts a topic
+			block = append(block, fmt.Sprintf("\n--- Topic starting at line %d ---", lineNum))
+			topic := parsedTopic(repo, snip, true)
+			detail.Kind = "topic"
+			detail.Topic = &topic
+
+			var topicLines []string
+			for i := snip.StartLine; i <= snip.EndLine; i++ {
+				if doc.Line(i) == "" {
+					continue
 				}
-				currentIndex++
+				topicLines = append(topicLines, fmt.Sprintf("Line %d: %s", i, doc.Line(i)))
 			}
-			
-			results = append(results, strings.Join(topicLines, "\n"))
+
+			block = append(block, strings.Join(topicLines, "\n"))
 		} else {
-			// For non-TITLE lines, provide context
-			results = append(results, fmt.Sprintf("\n--- Context around line %d ---", lineNum))
-			
-			contextStart := lineIndex - 3
-			if contextStart < 0 {
-				contextStart = 0
+			// For other lines, provide context
+			block = append(block, fmt.Sprintf("\n--- Context around line %d ---", lineNum))
+			detail.Kind = "context"
+			if snip, ok := doc.SnippetAt(lineNum); ok {
+				topic := parsedTopic(repo, snip, false)
+				detail.Topic = &topic
+			}
+
+			contextStart := lineNum - 3
+			if contextStart < 1 {
+				contextStart = 1
 			}
-			contextEnd := lineIndex + 3
-			if contextEnd >= len(lines) {
-				contextEnd = len(lines) - 1
+			contextEnd := lineNum + 3
+			if contextEnd > len(doc.Lines) {
+				contextEnd = len(doc.Lines)
 			}
-			
+
 			for i := contextStart; i <= contextEnd; i++ {
 				prefix := "  "
-				if i == lineIndex {
+				if i == lineNum {
 					prefix = "* " // Mark the requested line
 				}
-				results = append(results, fmt.Sprintf("%sLine %d: %s", prefix, i+1, lines[i]))
+				block = append(block, fmt.Sprintf("%sLine %d: %s", prefix, i, doc.Line(i)))
+				detail.Lines = append(detail.Lines, numberedLine{Line: i, Text: doc.Line(i)})
 			}
 		}
+
+		if budget.fit(renderBlock(outputFormat, block, detail)) {
+			results = append(results, block...)
+			details = append(details, detail)
+		}
+	}
+
+	if outputFormat == format.JSON {
+		return format.Marshal(topicDetailsResponse{Repo: repo, Details: details, Budget: budget.info()})
+	}
+
+	if summary := budget.summary(); summary != "" {
+		results = append(results, "", summary)
 	}
 
 	return strings.Join(results, "\n"), nil
@@ -415,98 +585,76 @@ func getTopicDetails(repo, lineNumbersStr string) (string, error) {
 func AddListRepositories(s *server.MCPServer) {
 	listTool := mcp.NewTool("list_repositories",
 		mcp.WithDescription("List all available repositories with their metadata and topic counts"),
+		mcp.WithNumber("max_tokens",
+			mcp.Description(maxTokensParam),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
 	)
 
 	s.AddTool(listTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		log.Printf("LIST_REPOSITORIES tool called")
 
-		results, err := listRepositories()
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("LIST_REPOSITORIES tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		results, err := listRepositories(request.GetInt("max_tokens", 0), outputFormat)
 		if err != nil {
 			log.Printf("LIST_REPOSITORIES tool error - listing failed: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("listing failed: %v", err)), nil
 		}
 
 		log.Printf("LIST_REPOSITORIES tool: Listed available repositories")
-		return mcp.NewToolResultText(results), nil
+		return format.Result(outputFormat, results), nil
 	})
 }
 
 // listRepositories lists all available repositories with metadata
-func listRepositories() (string, error) {
-	contextDir := "llm-context"
-	
-	// Check if context directory exists
-	if _, err := os.Stat(contextDir); os.IsNotExist(err) {
-		return "No context documents found. Please download some repositories first using save_context_document.", nil
+func listRepositories(maxTokens int, outputFormat string) (string, error) {
+	// Check if any context documents are stored
+	roots := store.Roots()
+	if len(roots) == 0 {
+		return message(outputFormat, noDocumentsMessage)
 	}
 
 	var results []string
 	results = append(results, "=== Available Repositories ===\n")
 
+	type KeywordCount struct {
+		Keyword string `json:"keyword"`
+		Count   int    `json:"count"`
+	}
+
 	type RepoInfo struct {
-		Name       string
-		TokenCount int
-		DateCreated string
-		TopicCount int
-		Keywords   []string
+		Name        string         `json:"repo"`
+		Store       string         `json:"store"`
+		TokenCount  int            `json:"token_count"`
+		DateCreated string         `json:"date_created,omitempty"`
+		TopicCount  int            `json:"topic_count"`
+		Keywords    []string       `json:"-"`
+		TopKeywords []KeywordCount `json:"keywords,omitempty"`
 	}
 
 	var repos []RepoInfo
 
-	err := filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Only process llms.txt files
-		if info.Name() != "llms.txt" {
-			return nil
-		}
-
-		// Extract repo info from path
-		relPath, err := filepath.Rel(contextDir, path)
-		if err != nil {
-			return err
-		}
-		pathParts := strings.Split(relPath, string(os.PathSeparator))
-		if len(pathParts) < 3 {
-			return nil
-		}
-		repoName := pathParts[0] + "/" + pathParts[1]
-
-		// Read file to get metadata and count topics
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Failed to read file %s: %v", path, err)
-			return nil
-		}
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
 
-		lines := strings.Split(string(content), "\n")
-		
-		var tokenCount int
-		var dateCreated string
-		var topicCount int
+	err = walkDocuments(ix, "", func(stored *index.Document, doc *snippet.Document) error {
 		var keywords []string
 
-		// Parse metadata header
-		for _, line := range lines {
-			if strings.HasPrefix(line, "# TOKEN_COUNT:") {
-				tokenStr := strings.TrimSpace(strings.TrimPrefix(line, "# TOKEN_COUNT:"))
-				if count, err := strconv.Atoi(tokenStr); err == nil {
-					tokenCount = count
-				}
-			} else if strings.HasPrefix(line, "# DATE_CREATED:") {
-				dateCreated = strings.TrimSpace(strings.TrimPrefix(line, "# DATE_CREATED:"))
-			} else if strings.HasPrefix(line, "TITLE:") {
-				topicCount++
-			}
-		}
-
 		// Extract common keywords from content
-		contentStr := strings.ToLower(string(content))
+		contentStr := strings.ToLower(strings.Join(doc.Lines[doc.Metadata.HeaderLines:], "\n"))
 		commonKeywords := []string{"server", "client", "config", "auth", "api", "tool", "mcp", "go", "typescript", "react", "database", "docker"}
 		keywordCounts := make(map[string]int)
-		
+
 		for _, keyword := range commonKeywords {
 			count := strings.Count(contentStr, keyword)
 			if count > 2 { // Only include frequently mentioned keywords
@@ -515,33 +663,34 @@ func listRepositories() (string, error) {
 		}
 
 		// Sort keywords by frequency and take top 3
-		type KeywordCount struct {
-			Keyword string
-			Count   int
-		}
 		var keywordList []KeywordCount
 		for k, v := range keywordCounts {
 			keywordList = append(keywordList, KeywordCount{k, v})
 		}
 		// Simple sort by count (descending)
 		for i := 0; i < len(keywordList)-1; i++ {
-			for j := i+1; j < len(keywordList); j++ {
+			for j := i + 1; j < len(keywordList); j++ {
 				if keywordList[j].Count > keywordList[i].Count {
 					keywordList[i], keywordList[j] = keywordList[j], keywordList[i]
 				}
 			}
 		}
-		
+
 		for i := 0; i < len(keywordList) && i < 3; i++ {
 			keywords = append(keywords, fmt.Sprintf("%s(%d)", keywordList[i].Keyword, keywordList[i].Count))
 		}
+		if len(keywordList) > 3 {
+			keywordList = keywordList[:3]
+		}
 
 		repos = append(repos, RepoInfo{
-			Name:        repoName,
-			TokenCount:  tokenCount,
-			DateCreated: dateCreated,
-			TopicCount:  topicCount,
+			Name:        stored.Repo,
+			Store:       store.Scope(stored.Root()),
+			TokenCount:  doc.Metadata.TokenCount,
+			DateCreated: doc.Metadata.DateCreated,
+			TopicCount:  len(doc.Snippets),
 			Keywords:    keywords,
+			TopKeywords: keywordList,
 		})
 
 		return nil
@@ -551,23 +700,46 @@ func listRepositories() (string, error) {
 		return "", fmt.Errorf("failed to scan repositories: %v", err)
 	}
 
+	budget := newTokenBudget(maxTokens)
+	listed := []any{}
+
 	if len(repos) == 0 {
 		results = append(results, "No repositories found.")
 	} else {
+		if outputFormat != format.JSON {
+			budget.reserve(results[0])
+		}
+
 		for _, repo := range repos {
-			results = append(results, fmt.Sprintf("📁 %s", repo.Name))
-			results = append(results, fmt.Sprintf("   Topics: %d", repo.TopicCount))
-			results = append(results, fmt.Sprintf("   Tokens: %d", repo.TokenCount))
+			block := []string{
+				fmt.Sprintf("📁 %s", repo.Name),
+				fmt.Sprintf("   Store: %s", repo.Store),
+				fmt.Sprintf("   Topics: %d", repo.TopicCount),
+				fmt.Sprintf("   Tokens: %d", repo.TokenCount),
+			}
 			if repo.DateCreated != "" {
-				results = append(results, fmt.Sprintf("   Downloaded: %s", repo.DateCreated))
+				block = append(block, fmt.Sprintf("   Downloaded: %s", repo.DateCreated))
 			}
 			if len(repo.Keywords) > 0 {
-				results = append(results, fmt.Sprintf("   Keywords: %s", strings.Join(repo.Keywords, " ")))
+				block = append(block, fmt.Sprintf("   Keywords: %s", strings.Join(repo.Keywords, " ")))
+			}
+			block = append(block, "")
+
+			if budget.fit(renderBlock(outputFormat, block, repo)) {
+				results = append(results, block...)
+				listed = append(listed, repo)
 			}
-			results = append(results, "")
+		}
+
+		if summary := budget.summary(); summary != "" {
+			results = append(results, summary)
 		}
 	}
 
+	if outputFormat == format.JSON {
+		return format.Marshal(repositoriesResponse{Repositories: listed, Budget: budget.info()})
+	}
+
 	return strings.Join(results, "\n"), nil
 }
 
@@ -577,7 +749,26 @@ func AddAnalyzeKeywords(s *server.MCPServer) {
 		mcp.WithDescription("Analyze keyword frequency across all repositories"),
 		mcp.WithString("keyword",
 			mcp.Required(),
-			mcp.Description("Keyword to analyze across all repositories"),
+			mcp.Description("Keyword or query to analyze across all repositories. "+querySyntax),
+		),
+		mcp.WithString("snapshot",
+			mcp.Description(snapshot.Param),
+		),
+		mcp.WithString("language",
+			mcp.Description(languageParam),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description(fmt.Sprintf("Maximum number of repositories per page, most matches first (defaults to %d)", defaultLimit)),
+		),
+		mcp.WithString("cursor",
+			mcp.Description(cursorParam),
+		),
+		mcp.WithNumber("max_tokens",
+			mcp.Description(maxTokensParam),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
 		),
 	)
 
@@ -590,107 +781,98 @@ func AddAnalyzeKeywords(s *server.MCPServer) {
 			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		results, err := analyzeKeywords(keyword)
+		opts, err := searchOptionsFromRequest(request)
+		if err != nil {
+			log.Printf("ANALYZE_KEYWORDS tool error - invalid parameters: %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		results, err := analyzeKeywords(keyword, opts)
 		if err != nil {
 			log.Printf("ANALYZE_KEYWORDS tool error - analysis failed: %v", err)
 			return mcp.NewToolResultError(fmt.Sprintf("analysis failed: %v", err)), nil
 		}
 
 		log.Printf("ANALYZE_KEYWORDS tool: Analyzed keyword '%s'", keyword)
-		return mcp.NewToolResultText(results), nil
+		return format.Result(opts.format, results), nil
 	})
 }
 
 // analyzeKeywords analyzes keyword frequency across all repositories
-func analyzeKeywords(keyword string) (string, error) {
-	contextDir := "llm-context"
-	
-	// Check if context directory exists
-	if _, err := os.Stat(contextDir); os.IsNotExist(err) {
-		return "No context documents found. Please download some repositories first using save_context_document.", nil
+func analyzeKeywords(keyword string, opts searchOptions) (string, error) {
+	// Check if any context documents are stored
+	roots := opts.roots
+	if len(roots) == 0 {
+		return message(opts.format, noDocumentsMessage)
 	}
 
 	var results []string
 	results = append(results, fmt.Sprintf("=== Keyword Analysis: '%s' ===\n", keyword))
 
 	type RepoMatch struct {
-		Name       string
-		Matches    int
-		TitleMatches int
-		DescMatches  int
-		CodeMatches  int
-		TopicCount int
+		Name           string `json:"repo"`
+		Matches        int    `json:"matches"`
+		TitleMatches   int    `json:"title_matches"`
+		DescMatches    int    `json:"description_matches"`
+		CodeMatches    int    `json:"code_matches"`
+		TopicCount     int    `json:"topic_count"`
+		MatchingTopics int    `json:"matching_topics"`
 	}
 
 	var repoMatches []RepoMatch
-	keywordLower := strings.ToLower(keyword)
-
-	err := filepath.Walk(contextDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-
-		// Only process llms.txt files
-		if info.Name() != "llms.txt" {
-			return nil
-		}
-
-		// Extract repo info from path
-		relPath, err := filepath.Rel(contextDir, path)
-		if err != nil {
-			return err
-		}
-		pathParts := strings.Split(relPath, string(os.PathSeparator))
-		if len(pathParts) < 3 {
-			return nil
-		}
-		repoName := pathParts[0] + "/" + pathParts[1]
+	node, err := parseQuery(keyword)
+	if err != nil {
+		return "", err
+	}
+	node = withLanguage(node, opts.language)
+	terms := node.Terms()
 
-		// Read file content
-		content, err := os.ReadFile(path)
-		if err != nil {
-			log.Printf("Failed to read file %s: %v", path, err)
-			return nil
-		}
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		return "", fmt.Errorf("failed to load search index: %v", err)
+	}
 
-		lines := strings.Split(string(content), "\n")
-		
-		var titleMatches, descMatches, codeMatches, topicCount int
-		inCodeBlock := false
+	err = walkDocuments(ix, opts.repoFilter, func(stored *index.Document, doc *snippet.Document) error {
+		var titleMatches, descMatches, codeMatches, matchingTopics int
+		topicCount := len(doc.Snippets)
 
-		for _, line := range lines {
-			// Skip metadata header lines
-			if strings.HasPrefix(line, "#") {
+		for i := range doc.Snippets {
+			snip := &doc.Snippets[i]
+			if !node.Match(snip, snippet.MaskAll) {
 				continue
 			}
+			matchingTopics++
+
+			for _, line := range snip.Lines() {
+				if !inLanguage(snip, line, opts.language) {
+					continue
+				}
+				lineMatches := 0
+				for _, term := range terms {
+					lineMatches += term.Count(line.Text, line.Field, snippet.MaskAll)
+				}
 
-			lineLower := strings.ToLower(line)
-			lineMatches := strings.Count(lineLower, keywordLower)
-
-			if strings.HasPrefix(line, "TITLE:") {
-				topicCount++
-				titleMatches += lineMatches
-			} else if strings.HasPrefix(line, "DESCRIPTION:") {
-				descMatches += lineMatches
-			} else if strings.HasPrefix(line, "CODE:") {
-				inCodeBlock = true
-				codeMatches += lineMatches
-			} else if strings.Contains(line, "----------------------------------------") {
-				inCodeBlock = false
-			} else if inCodeBlock {
-				codeMatches += lineMatches
+				switch line.Field {
+				case snippet.FieldTitle:
+					titleMatches += lineMatches
+				case snippet.FieldDescription:
+					descMatches += lineMatches
+				case snippet.FieldCode:
+					codeMatches += lineMatches
+				}
 			}
 		}
 
 		totalMatches := titleMatches + descMatches + codeMatches
-		if totalMatches > 0 {
+		if matchingTopics > 0 {
 			repoMatches = append(repoMatches, RepoMatch{
-				Name:         repoName,
-				Matches:      totalMatches,
-				TitleMatches: titleMatches,
-				DescMatches:  descMatches,
-				CodeMatches:  codeMatches,
-				TopicCount:   topicCount,
+				Name:           stored.Repo,
+				Matches:        totalMatches,
+				TitleMatches:   titleMatches,
+				DescMatches:    descMatches,
+				CodeMatches:    codeMatches,
+				TopicCount:     topicCount,
+				MatchingTopics: matchingTopics,
 			})
 		}
 
@@ -701,12 +883,17 @@ func analyzeKeywords(keyword string) (string, error) {
 		return "", fmt.Errorf("failed to analyze keyword: %v", err)
 	}
 
+	page, err := newPager(opts, ix, "keywords", keyword)
+	if err != nil {
+		return "", err
+	}
+
 	if len(repoMatches) == 0 {
 		results = append(results, "No matches found across any repositories.")
 	} else {
 		// Sort repositories by total matches (descending)
 		for i := 0; i < len(repoMatches)-1; i++ {
-			for j := i+1; j < len(repoMatches); j++ {
+			for j := i + 1; j < len(repoMatches); j++ {
 				if repoMatches[j].Matches > repoMatches[i].Matches {
 					repoMatches[i], repoMatches[j] = repoMatches[j], repoMatches[i]
 				}
@@ -715,10 +902,14 @@ func analyzeKeywords(keyword string) (string, error) {
 
 		results = append(results, fmt.Sprintf("Found %d repositories with matches:", len(repoMatches)))
 		results = append(results, "")
+		page.reserve(strings.Join(results, "\n"))
+
+		for i, repo := range repoMatches {
+			if !page.inPage(i) {
+				continue
+			}
+			block := []string{fmt.Sprintf("📁 %s: %d total matches", repo.Name, repo.Matches)}
 
-		for _, repo := range repoMatches {
-			results = append(results, fmt.Sprintf("📁 %s: %d total matches", repo.Name, repo.Matches))
-			
 			breakdown := []string{}
 			if repo.TitleMatches > 0 {
 				breakdown = append(breakdown, fmt.Sprintf("titles(%d)", repo.TitleMatches))
@@ -729,18 +920,21 @@ func analyzeKeywords(keyword string) (string, error) {
 			if repo.CodeMatches > 0 {
 				breakdown = append(breakdown, fmt.Sprintf("code(%d)", repo.CodeMatches))
 			}
-			
+
 			if len(breakdown) > 0 {
-				results = append(results, fmt.Sprintf("   Breakdown: %s", strings.Join(breakdown, ", ")))
+				block = append(block, fmt.Sprintf("   Breakdown: %s", strings.Join(breakdown, ", ")))
 			}
-			
+
 			// Calculate relevance percentage
 			relevance := 0.0
 			if repo.TopicCount > 0 {
 				relevance = (float64(repo.Matches) / float64(repo.TopicCount)) * 100
 			}
-			results = append(results, fmt.Sprintf("   Relevance: %.1f%% (%d matches in %d topics)", relevance, repo.Matches, repo.TopicCount))
-			results = append(results, "")
+			block = append(block, fmt.Sprintf("   Relevance: %.1f%% (%d matches in %d topics)", relevance, repo.Matches, repo.TopicCount))
+			block = append(block, fmt.Sprintf("   Matching topics: %d", repo.MatchingTopics))
+			block = append(block, "")
+
+			results = page.add(results, block, repo)
 		}
 
 		// Summary statistics
@@ -749,14 +943,85 @@ func analyzeKeywords(keyword string) (string, error) {
 		for _, repo := range repoMatches {
 			totalMatches += repo.Matches
 		}
-		
+
 		results = append(results, "--- Summary ---")
 		results = append(results, fmt.Sprintf("Total matches: %d across %d repositories", totalMatches, totalRepos))
 		if totalRepos > 0 {
 			avgMatches := float64(totalMatches) / float64(totalRepos)
 			results = append(results, fmt.Sprintf("Average matches per repository: %.1f", avgMatches))
 		}
+		results = append(results, "")
+		results = append(results, page.summary(totalRepos, "repositories")...)
+	}
+
+	if opts.format == format.JSON {
+		totalMatches := 0
+		for _, repo := range repoMatches {
+			totalMatches += repo.Matches
+		}
+		return format.Marshal(keywordResponse{Keyword: keyword, TotalMatches: totalMatches, Repositories: page.items, Page: page.info(len(repoMatches))})
 	}
 
 	return strings.Join(results, "\n"), nil
-}
\ No newline at end of file
+}
+
+// documentPath returns the llms.txt file of repo in the first of roots
+// holding it, ignoring files that are not regular files inside the stores
+func documentPath(repo store.Repo, roots []string) (string, bool) {
+	for _, root := range roots {
+		path := repo.File(root)
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if err := store.CheckDir(filepath.Dir(path)); err != nil {
+			log.Printf("Skipping %s: %v", path, err)
+			continue
+		}
+		return path, true
+	}
+	return "", false
+}
+
+// walkDocuments parses every llms.txt document of the search index, in index
+// order, and calls fn with its index entry, skipping repositories other than
+// repoFilter when one is given
+func walkDocuments(ix *index.Index, repoFilter string, fn func(stored *index.Document, doc *snippet.Document) error) error {
+	for i := range ix.Documents {
+		stored := &ix.Documents[i]
+		// Apply repository filter if specified
+		if repoFilter != "" && stored.Repo != repoFilter {
+			continue
+		}
+
+		path := stored.File()
+		doc, err := snippet.ParseFile(path)
+		if err != nil {
+			log.Printf("Failed to read file %s: %v", path, err)
+			continue
+		}
+
+		if err := fn(stored, doc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexedSnippet rebuilds the title, description and languages of an indexed
+// snippet, which is enough to evaluate queries that do not search code
+func indexedSnippet(entry *index.Snippet) *snippet.Snippet {
+	snip := &snippet.Snippet{
+		Title:           entry.Title,
+		Description:     entry.Description,
+		StartLine:       entry.StartLine,
+		EndLine:         entry.EndLine,
+		TitleLine:       entry.TitleLine,
+		DescriptionLine: entry.DescriptionLine,
+	}
+	for _, language := range entry.Languages {
+		snip.Examples = append(snip.Examples, snippet.Example{Language: language})
+	}
+	return snip
+}
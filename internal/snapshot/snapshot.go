@@ -0,0 +1,468 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"docs4context-com/internal/format"
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/snippet"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// IDLayout is the time layout of snapshot IDs: the time the documents of
+// the snapshot were downloaded, in UTC. Snapshots are kept in
+// store.SnapshotDir of a store root, each in a directory named by its ID and
+// laid out like a store, so that it can be searched like one.
+const IDLayout = "20060102T150405Z"
+
+// idRegex matches a snapshot ID
+var idRegex = regexp.MustCompile(`^\d{8}T\d{6}Z$`)
+
+// Snapshot is a stored version of a document, or the current one
+type Snapshot struct {
+	// ID is the snapshot ID, or "current" for the document in use
+	ID          string `json:"id"`
+	Repo        string `json:"repo"`
+	Store       string `json:"store"`
+	Path        string `json:"path"`
+	DateCreated string `json:"date_created"`
+	TokenCount  int    `json:"token_count"`
+	Snippets    int    `json:"snippets"`
+}
+
+// Current is the ID listing the document in use next to its snapshots
+const Current = "current"
+
+// Param documents the snapshot parameter of the search tools
+const Param = "Search the snapshot with this ID (e.g. '20250626T103045Z', see list_context_snapshots): repositories with a snapshot of that ID are searched as they were then, the others as they are now"
+
+// listResponse is the JSON form of list_context_snapshots
+type listResponse struct {
+	Repo      string     `json:"repo"`
+	Retention int        `json:"retention"`
+	Snapshots []Snapshot `json:"snapshots"`
+}
+
+// AddListTool adds the tool listing the snapshots of a document
+func AddListTool(s *server.MCPServer) {
+	listTool := mcp.NewTool("list_context_snapshots",
+		mcp.WithDescription("List the earlier versions of a repository's context document that were kept when it was replaced, newest first, along with the current version"),
+		mcp.WithString("repo",
+			mcp.Required(),
+			mcp.Description("Repository to list the snapshots of, as 'owner/repo' or 'owner/repo@variant'"),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(listTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("LIST_CONTEXT_SNAPSHOTS tool called")
+
+		repo, err := request.RequireString("repo")
+		if err != nil {
+			log.Printf("LIST_CONTEXT_SNAPSHOTS tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		repoID, err := store.ParseRepo(repo)
+		if err != nil {
+			log.Printf("LIST_CONTEXT_SNAPSHOTS tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("LIST_CONTEXT_SNAPSHOTS tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		snapshots, err := List(store.Roots(), repoID)
+		if err != nil {
+			log.Printf("LIST_CONTEXT_SNAPSHOTS tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(listResponse{Repo: repoID.String(), Retention: store.SnapshotRetention(), Snapshots: snapshots})
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(FormatList(repoID, snapshots)), nil
+	})
+}
+
+// AddRestoreTool adds the tool restoring a snapshot of a document
+func AddRestoreTool(s *server.MCPServer) {
+	restoreTool := mcp.NewTool("restore_context_snapshot",
+		mcp.WithDescription("Make a snapshot of a repository's context document the current version again; the version it replaces is kept as a snapshot"),
+		mcp.WithString("repo",
+			mcp.Required(),
+			mcp.Description("Repository to restore, as 'owner/repo' or 'owner/repo@variant'"),
+		),
+		mcp.WithString("snapshot",
+			mcp.Required(),
+			mcp.Description("ID of the snapshot to restore, as listed by list_context_snapshots"),
+		),
+		mcp.WithString("format",
+			mcp.Description(format.Param),
+			mcp.Enum(format.Text, format.JSON),
+		),
+	)
+
+	s.AddTool(restoreTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		log.Printf("RESTORE_CONTEXT_SNAPSHOT tool called")
+
+		repo, err := request.RequireString("repo")
+		if err != nil {
+			log.Printf("RESTORE_CONTEXT_SNAPSHOT tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		repoID, err := store.ParseRepo(repo)
+		if err != nil {
+			log.Printf("RESTORE_CONTEXT_SNAPSHOT tool error - invalid parameter 'repo': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		id, err := request.RequireString("snapshot")
+		if err != nil {
+			log.Printf("RESTORE_CONTEXT_SNAPSHOT tool error - invalid parameter 'snapshot': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		outputFormat, err := format.FromRequest(request)
+		if err != nil {
+			log.Printf("RESTORE_CONTEXT_SNAPSHOT tool error - invalid parameter 'format': %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		restored, err := Restore(store.Roots(), repoID, id)
+		if err != nil {
+			log.Printf("RESTORE_CONTEXT_SNAPSHOT tool error - %v", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if outputFormat == format.JSON {
+			text, err := format.Marshal(restored)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return format.Result(outputFormat, text), nil
+		}
+		return mcp.NewToolResultText(FormatRestore(id, restored)), nil
+	})
+}
+
+var (
+	rootLocksMu sync.Mutex
+	rootLocks   = make(map[string]*sync.Mutex)
+)
+
+// lockRoot serialises changes to the snapshots of a store root, so that
+// pruning does not remove a snapshot directory another document is being
+// copied into. It returns the function releasing the lock.
+func lockRoot(root string) func() {
+	key, err := filepath.Abs(root)
+	if err != nil {
+		key = root
+	}
+	rootLocksMu.Lock()
+	mu, ok := rootLocks[key]
+	if !ok {
+		mu = &sync.Mutex{}
+		rootLocks[key] = mu
+	}
+	rootLocksMu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// Take keeps a copy of the document at path, inside a store root, as a
+// snapshot before it is replaced, then prunes the snapshots of the document
+// beyond the retention. The snapshot is named after the document's
+// DATE_CREATED. Nothing is kept when the retention is 0, the document does
+// not exist yet or the snapshot was already taken.
+func Take(path string) error {
+	keep := store.SnapshotRetention()
+	if keep == 0 {
+		return nil
+	}
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	// The document lies at root/owner/repo/llms.txt
+	root := filepath.Dir(filepath.Dir(filepath.Dir(path)))
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return err
+	}
+	repo, ok := store.RepoFromPath(rel)
+	if !ok {
+		return nil
+	}
+
+	unlock := lockRoot(root)
+	defer unlock()
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	id := info.ModTime().UTC().Format(IDLayout)
+	if created, err := time.Parse(time.RFC3339, snippet.Parse(content).Metadata.DateCreated); err == nil {
+		id = created.UTC().Format(IDLayout)
+	}
+
+	target := repo.File(filepath.Join(root, store.SnapshotDir, id))
+	if _, err := os.Lstat(target); os.IsNotExist(err) {
+		if err := store.CheckDir(filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(target), err)
+		}
+		if err := os.WriteFile(target, content, 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot %s: %v", target, err)
+		}
+		log.Printf("Kept snapshot %s of %s", id, repo)
+	}
+
+	return prune(root, repo, keep)
+}
+
+// prune removes the oldest snapshots of a document beyond the keep newest
+func prune(root string, repo store.Repo, keep int) error {
+	ids, err := snapshotIDs(root, repo)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids[min(keep, len(ids)):] {
+		dir := filepath.Join(root, store.SnapshotDir, id)
+		if err := os.Remove(repo.File(dir)); err != nil {
+			return fmt.Errorf("failed to remove snapshot %s of %s: %v", id, repo, err)
+		}
+		log.Printf("Removed snapshot %s of %s", id, repo)
+
+		// Removing a directory fails while it still holds files, which is
+		// fine; a snapshot left without documents goes with its index
+		if os.Remove(repo.Dir(dir)) == nil {
+			os.Remove(filepath.Dir(repo.Dir(dir)))
+		}
+		entries, err := os.ReadDir(dir)
+		if err == nil && (len(entries) == 0 || (len(entries) == 1 && entries[0].Name() == ".index")) {
+			os.RemoveAll(filepath.Join(dir, ".index"))
+			os.Remove(dir)
+		}
+	}
+	return nil
+}
+
+// snapshotIDs returns the IDs of the snapshots of a document in root, newest first
+func snapshotIDs(root string, repo store.Repo) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(root, store.SnapshotDir))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshots of %s: %v", root, err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !idRegex.MatchString(entry.Name()) {
+			continue
+		}
+		info, err := os.Lstat(repo.File(filepath.Join(root, store.SnapshotDir, entry.Name())))
+		if err == nil && info.Mode().IsRegular() {
+			ids = append(ids, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+	return ids, nil
+}
+
+// List returns the current document of repo and its snapshots in each of
+// roots, newest first
+func List(roots []string, repo store.Repo) ([]Snapshot, error) {
+	var snapshots []Snapshot
+	for _, root := range roots {
+		if current, err := describe(Current, repo, root, repo.File(root)); err == nil {
+			snapshots = append(snapshots, current)
+		}
+		ids, err := snapshotIDs(root, repo)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			snap, err := describe(id, repo, root, repo.File(filepath.Join(root, store.SnapshotDir, id)))
+			if err != nil {
+				log.Printf("Skipping snapshot %s of %s: %v", id, repo, err)
+				continue
+			}
+			snapshots = append(snapshots, snap)
+		}
+	}
+	if len(snapshots) == 0 {
+		return nil, fmt.Errorf("repository '%s' not found in the stores", repo)
+	}
+	return snapshots, nil
+}
+
+// describe reads the metadata of a stored document
+func describe(id string, repo store.Repo, root, path string) (Snapshot, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Snapshot{}, fmt.Errorf("%s is not a regular file", path)
+	}
+	doc, err := snippet.ParseFile(path)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{
+		ID:          id,
+		Repo:        repo.String(),
+		Store:       store.Scope(root),
+		Path:        path,
+		DateCreated: doc.Metadata.DateCreated,
+		TokenCount:  doc.Metadata.TokenCount,
+		Snippets:    len(doc.Snippets),
+	}, nil
+}
+
+// Restore makes the snapshot id of repo, in the first of roots holding it,
+// the current document again. The document it replaces is kept as a
+// snapshot first.
+func Restore(roots []string, repo store.Repo, id string) (Snapshot, error) {
+	if err := checkID(id); err != nil {
+		return Snapshot{}, err
+	}
+	for _, root := range roots {
+		path := repo.File(filepath.Join(root, store.SnapshotDir, id))
+		info, err := os.Lstat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return Snapshot{}, fmt.Errorf("failed to read snapshot %s: %v", path, err)
+		}
+		current := repo.File(root)
+		if err := store.CheckDir(filepath.Dir(current)); err != nil {
+			return Snapshot{}, err
+		}
+		if err := Take(current); err != nil {
+			return Snapshot{}, fmt.Errorf("failed to keep the current document: %v", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(current), 0755); err != nil {
+			return Snapshot{}, fmt.Errorf("failed to create directory %s: %v", filepath.Dir(current), err)
+		}
+		if err := os.WriteFile(current, content, 0644); err != nil {
+			return Snapshot{}, fmt.Errorf("failed to write file %s: %v", current, err)
+		}
+		log.Printf("Restored snapshot %s of %s to %s", id, repo, current)
+
+		if err := index.Update(root); err != nil {
+			log.Printf("Warning - failed to update search index of %s: %v", root, err)
+		}
+		return describe(Current, repo, root, current)
+	}
+	return Snapshot{}, fmt.Errorf("no snapshot %s of %s; use list_context_snapshots to see the snapshots", id, repo)
+}
+
+// Path returns the file of a version of the document of repo in the first
+// of roots holding it: the current document for Current, or else the
+// snapshot with ID id
+func Path(roots []string, repo store.Repo, id string) (string, error) {
+	if id != Current {
+		if err := checkID(id); err != nil {
+			return "", err
+		}
+	}
+	for _, root := range roots {
+		path := repo.File(root)
+		if id != Current {
+			path = repo.File(filepath.Join(root, store.SnapshotDir, id))
+		}
+		if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+	if id == Current {
+		return "", fmt.Errorf("repository '%s' not found in the stores", repo)
+	}
+	return "", fmt.Errorf("no snapshot %s of %s; use list_context_snapshots to see the snapshots", id, repo)
+}
+
+// Roots returns the directories the search tools search for snapshot id:
+// the directories of the snapshot in the store roots, followed by the store
+// roots. A snapshot only holds the documents replaced after downloading them
+// at that time, and a search takes each repository from the first directory
+// holding it, so as with Path the snapshot of a repository is searched in
+// place of its current document, next to the current documents of the others.
+func Roots(id string) ([]string, error) {
+	if err := checkID(id); err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, root := range store.Roots() {
+		dir := filepath.Join(root, store.SnapshotDir, id)
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return nil, fmt.Errorf("no snapshot %s in the stores; use list_context_snapshots to see the snapshots", id)
+	}
+	return append(dirs, store.Roots()...), nil
+}
+
+// checkID validates a snapshot ID, which is joined to store roots
+func checkID(id string) error {
+	if !idRegex.MatchString(id) {
+		return fmt.Errorf("invalid snapshot %q: expected an ID like %s", id, IDLayout)
+	}
+	return nil
+}
+
+// FormatList renders the snapshots of a document as text
+func FormatList(repo store.Repo, snapshots []Snapshot) string {
+	lines := []string{fmt.Sprintf("=== Snapshots of %s (keeping %d per document) ===", repo, store.SnapshotRetention()), ""}
+	for _, snap := range snapshots {
+		lines = append(lines, fmt.Sprintf("%-16s  downloaded %s  %d snippets, %d tokens  (%s store: %s)",
+			snap.ID, snap.DateCreated, snap.Snippets, snap.TokenCount, snap.Store, snap.Path))
+	}
+	if len(snapshots) == 1 && snapshots[0].ID == Current {
+		lines = append(lines, "", "No snapshots yet; one is kept each time the document is replaced by a different version.")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// FormatRestore renders the result of a restore as text
+func FormatRestore(id string, restored Snapshot) string {
+	return fmt.Sprintf("Restored snapshot %s of %s to %s\nDownloaded: %s\nSnippets: %d\nTokens: %d",
+		id, restored.Repo, restored.Path, restored.DateCreated, restored.Snippets, restored.TokenCount)
+}
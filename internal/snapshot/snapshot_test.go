@@ -0,0 +1,130 @@
+package snapshot
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"docs4context-com/internal/index"
+	"docs4context-com/internal/store"
+)
+
+// configureStore points the project store at a temporary directory with the
+// given snapshot retention and returns its root
+func configureStore(t *testing.T, retention int) string {
+	t.Helper()
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte(fmt.Sprintf(`{"snapshot_retention": %d}`, retention)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(dir, "llm-context")
+	if err := os.MkdirAll(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Configure(store.Options{Root: root, GlobalRoot: filepath.Join(dir, "global"), ConfigPath: configPath}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		store.Configure(store.Options{ConfigPath: configPath})
+	})
+	return root
+}
+
+// writeDocument stores a document of repo downloaded at created with one
+// topic titled title, and returns its path
+func writeDocument(t *testing.T, root, repo, created, title string) string {
+	t.Helper()
+	repoID, err := store.ParseRepo(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := repoID.File(root)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, documentContent(repo, created, title), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// documentContent renders a document of repo downloaded at created with one
+// topic titled title
+func documentContent(repo, created, title string) []byte {
+	return []byte(fmt.Sprintf("# METADATA\n# TOKEN_COUNT: 10\n# DATE_CREATED: %s\n# REPO: %s\n# SOURCE: s\n#\nTITLE: %s\nDESCRIPTION: d\nSOURCE: s\n", created, repo, title))
+}
+
+func TestRootsSearchSnapshotNextToCurrentDocuments(t *testing.T) {
+	root := configureStore(t, 5)
+
+	// a/b was replaced after its download of 2025-01-01; c/d never was
+	path := writeDocument(t, root, "a/b", "2025-01-01T00:00:00Z", "Old routing")
+	if err := Take(path); err != nil {
+		t.Fatal(err)
+	}
+	writeDocument(t, root, "a/b", "2025-02-01T00:00:00Z", "New routing")
+	writeDocument(t, root, "c/d", "2024-06-01T00:00:00Z", "Caching")
+
+	roots, err := Roots("20250101T000000Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix, err := index.LoadAll(roots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make(map[string]string)
+	for _, doc := range ix.Documents {
+		titles[doc.Repo] = doc.Snippets[0].Title
+	}
+	want := map[string]string{"a/b": "Old routing", "c/d": "Caching"}
+	if fmt.Sprint(titles) != fmt.Sprint(want) {
+		t.Errorf("documents searched in the snapshot = %v, want %v", titles, want)
+	}
+
+	if _, err := Roots("20240101T000000Z"); err == nil {
+		t.Error("Roots of a snapshot that does not exist succeeded")
+	}
+	if _, err := Roots("../x"); err == nil {
+		t.Error("Roots of an invalid ID succeeded")
+	}
+}
+
+func TestTakeConcurrentlyInOneRoot(t *testing.T) {
+	root := configureStore(t, 1)
+
+	// Every document was first downloaded in the same second, so their
+	// snapshots share a directory which pruning empties and removes
+	const repos = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*repos)
+	for i := 0; i < repos; i++ {
+		repo := fmt.Sprintf("owner/repo%d", i)
+		path := writeDocument(t, root, repo, "2025-01-01T00:00:00Z", "First")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- Take(path)
+			errs <- os.WriteFile(path, documentContent(repo, "2025-02-01T00:00:00Z", "Second"), 0644)
+			errs <- Take(path)
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Take failed: %v", err)
+		}
+	}
+
+	for i := 0; i < repos; i++ {
+		repo, _ := store.ParseRepo(fmt.Sprintf("owner/repo%d", i))
+		ids, err := snapshotIDs(root, repo)
+		if err != nil || len(ids) != 1 || ids[0] != "20250201T000000Z" {
+			t.Errorf("snapshots of %s = %v, %v, want only 20250201T000000Z", repo, ids, err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package snippet
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// headingRegex matches an ATX markdown heading
+	headingRegex = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	// linkItemRegex matches an llms.txt link list item: "- [title](url): notes"
+	linkItemRegex = regexp.MustCompile(`^\s*[-*+]\s+\[([^\]]+)\]\(([^)\s]+)[^)]*\)\s*(?::\s*(.*))?$`)
+	// fenceRegex matches the opening line of a fenced code block
+	fenceRegex = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([^\\s`]*)")
+)
+
+// fieldPrefixes are the markers the parser recognises at the start of a line
+var fieldPrefixes = []string{"TITLE:", "DESCRIPTION:", "SOURCE:", "LANGUAGE:", "CODE:"}
+
+// FromMarkdown converts a document following the llms.txt markdown
+// convention (https://llmstxt.org), as published at /llms.txt or
+// /llms-full.txt, into the snippet format. Every heading starts a snippet
+// whose description is the prose below it and whose examples are its fenced
+// code blocks; every "- [title](url): notes" link becomes a snippet of its
+// own. Relative links are resolved against documentURL, which is also the
+// SOURCE of heading snippets. Sections with neither prose nor code are
+// dropped.
+func FromMarkdown(content []byte, documentURL string) []byte {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	base, _ := url.Parse(documentURL)
+
+	var snippets []*Snippet
+	var current *Snippet
+	var prose []string
+	flush := func() {
+		if current != nil {
+			current.Description = strings.Join(prose, "\n")
+			if current.Description != "" || len(current.Examples) > 0 {
+				snippets = append(snippets, current)
+			}
+		}
+		current, prose = nil, nil
+	}
+
+	fence := ""
+	var code []string
+	for _, line := range lines {
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				current.Examples[len(current.Examples)-1].Code = strings.Join(code, "\n")
+				fence, code = "", nil
+				continue
+			}
+			code = append(code, line)
+			continue
+		}
+
+		if match := fenceRegex.FindStringSubmatch(line); match != nil {
+			if current == nil {
+				current = &Snippet{Title: "Overview", Source: documentURL}
+			}
+			language := match[2]
+			if language == "" {
+				language = "text"
+			}
+			current.Examples = append(current.Examples, Example{Language: language})
+			fence = match[1]
+			continue
+		}
+
+		if match := headingRegex.FindStringSubmatch(line); match != nil {
+			flush()
+			current = &Snippet{Title: match[2], Source: documentURL}
+			continue
+		}
+
+		if match := linkItemRegex.FindStringSubmatch(line); match != nil {
+			snippets = append(snippets, &Snippet{
+				Title:       match[1],
+				Description: match[3],
+				Source:      resolveLink(base, match[2]),
+			})
+			continue
+		}
+
+		text := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), ">"))
+		if text == "" || text == Separator {
+			continue
+		}
+		if current == nil {
+			current = &Snippet{Title: "Overview", Source: documentURL}
+		}
+		prose = append(prose, escapeField(text))
+	}
+	if fence != "" {
+		current.Examples[len(current.Examples)-1].Code = strings.Join(code, "\n")
+	}
+	flush()
+
+	return Render(snippets)
+}
+
+// Render writes snippets in the snippet format used by context7
+func Render(snippets []*Snippet) []byte {
+	var b strings.Builder
+	for i, snip := range snippets {
+		if i > 0 {
+			b.WriteString("\n" + Separator + "\n\n")
+		}
+		b.WriteString("TITLE: " + oneLine(snip.Title) + "\n")
+		if snip.Description != "" {
+			b.WriteString("DESCRIPTION: " + snip.Description + "\n")
+		}
+		if snip.Source != "" {
+			b.WriteString("SOURCE: " + oneLine(snip.Source) + "\n")
+		}
+		for _, example := range snip.Examples {
+			b.WriteString("\nLANGUAGE: " + oneLine(example.Language) + "\nCODE:\n" + codeFence + "\n")
+			if example.Code != "" {
+				b.WriteString(example.Code + "\n")
+			}
+			b.WriteString(codeFence + "\n")
+		}
+	}
+	return []byte(b.String())
+}
+
+// resolveLink makes a link relative to the document absolute
+func resolveLink(base *url.URL, link string) string {
+	ref, err := url.Parse(link)
+	if err != nil || base == nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// escapeField indents a prose line that would otherwise be read as a field marker
+func escapeField(line string) string {
+	for _, prefix := range fieldPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return " " + line
+		}
+	}
+	return line
+}
+
+// oneLine joins the lines of a single-line field
+func oneLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
@@ -0,0 +1,111 @@
+package snippet
+
+import (
+	"reflect"
+	"testing"
+)
+
+const markdown = `# FastHTML
+
+> FastHTML is a python library for building web applications.
+
+Important notes:
+
+- It is not compatible with FastAPI syntax.
+TITLE: a line that looks like a field
+
+## Docs
+
+- [Quick start](/docs/quickstart.md): A brief overview
+- [HTMX reference](https://htmx.org/reference/)
+
+## Examples
+
+` + "```python" + `
+from fasthtml.common import *
+app, rt = fast_app()
+` + "```" + `
+
+` + "~~~" + `
+plain
+` + "~~~" + `
+
+## Empty section
+
+## Optional
+`
+
+func TestFromMarkdown(t *testing.T) {
+	converted := FromMarkdown([]byte(markdown), "https://fastht.ml/docs/llms.txt")
+	doc := Parse(converted)
+	if err := doc.Validate(); err != nil {
+		t.Fatalf("converted document is invalid: %v\n%s", err, converted)
+	}
+
+	type topic struct {
+		Title, Description, Source string
+		Examples                   []Example
+	}
+	want := []topic{
+		{
+			Title:       "FastHTML",
+			Description: "FastHTML is a python library for building web applications.\nImportant notes:\n- It is not compatible with FastAPI syntax.\n TITLE: a line that looks like a field",
+			Source:      "https://fastht.ml/docs/llms.txt",
+		},
+		{Title: "Quick start", Description: "A brief overview", Source: "https://fastht.ml/docs/quickstart.md"},
+		{Title: "HTMX reference", Source: "https://htmx.org/reference/"},
+		{
+			Title:  "Examples",
+			Source: "https://fastht.ml/docs/llms.txt",
+			Examples: []Example{
+				{Language: "python", Code: "from fasthtml.common import *\napp, rt = fast_app()"},
+				{Language: "text", Code: "plain"},
+			},
+		},
+	}
+	var got []topic
+	for _, snip := range doc.Snippets {
+		examples := []Example(nil)
+		for _, example := range snip.Examples {
+			examples = append(examples, Example{Language: example.Language, Code: example.Code})
+		}
+		got = append(got, topic{snip.Title, snip.Description, snip.Source, examples})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("converted topics = %+v\nwant %+v\ndocument:\n%s", got, want, converted)
+	}
+}
+
+func TestFromMarkdownRoundTrip(t *testing.T) {
+	inputs := map[string]string{
+		"llms.txt":         markdown,
+		"prose only":       "Just a paragraph\nover two lines.\n",
+		"code only":        "```go\nfmt.Println()\n```\n",
+		"unterminated":     "# A\n```go\nfmt.Println()\n",
+		"windows newlines": "# A\r\nSome prose.\r\n",
+		"link list":        "- [A](a.md)\n- [B](../b.md): notes\n",
+	}
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			converted := FromMarkdown([]byte(input), "https://example.com/docs/llms.txt")
+			doc := Parse(converted)
+			if err := doc.Validate(); err != nil {
+				t.Fatalf("converted document is invalid: %v\n%s", err, converted)
+			}
+
+			// Rendering the parsed snippets gives back the same document
+			var snippets []*Snippet
+			for i := range doc.Snippets {
+				snippets = append(snippets, &doc.Snippets[i])
+			}
+			if rendered := Render(snippets); string(rendered) != string(converted) {
+				t.Errorf("rendered again:\n%s\nwant:\n%s", rendered, converted)
+			}
+
+		})
+	}
+
+	if converted := FromMarkdown([]byte("## Heading only\n\n## Another\n"), "u"); len(converted) != 0 {
+		t.Errorf("sections without prose or code = %q, want nothing", converted)
+	}
+}
@@ -0,0 +1,382 @@
+package snippet
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Separator is the line context7 places between snippets in an llms.txt file
+const Separator = "----------------------------------------"
+
+const codeFence = "```"
+
+// Field identifies which part of a snippet a line belongs to
+type Field int
+
+const (
+	FieldTitle Field = iota
+	FieldDescription
+	FieldCode
+)
+
+// FieldMask is a set of fields
+type FieldMask uint8
+
+const (
+	MaskTitle       FieldMask = 1 << FieldTitle
+	MaskDescription FieldMask = 1 << FieldDescription
+	MaskCode        FieldMask = 1 << FieldCode
+	MaskAll                   = MaskTitle | MaskDescription | MaskCode
+)
+
+// Mask returns the set containing only this field
+func (f Field) Mask() FieldMask {
+	return 1 << f
+}
+
+// String returns the lowercase name of the field
+func (f Field) String() string {
+	switch f {
+	case FieldTitle:
+		return "title"
+	case FieldDescription:
+		return "description"
+	case FieldCode:
+		return "code"
+	}
+	return "unknown"
+}
+
+// Metadata holds the values from the "# METADATA" header written by save_context_document
+type Metadata struct {
+	TokenCount  int
+	DateCreated string
+	Repo        string
+	Source      string
+	// Fields contains every "# KEY: value" pair found in the header, including the ones above
+	Fields map[string]string
+	// HeaderLines is the number of lines the header occupies at the top of the file
+	HeaderLines int
+}
+
+// Example is a single LANGUAGE/CODE pair within a snippet
+type Example struct {
+	Language string
+	Code     string
+	// LanguageLine is the line of the LANGUAGE: marker
+	LanguageLine int
+	// CodeLine is the line of the first line of code, or 0 when the code is empty
+	CodeLine int
+}
+
+// Snippet is a single topic block of an llms.txt file. All line numbers are
+// 1-based and refer to the file as stored, including the metadata header.
+type Snippet struct {
+	Title       string
+	Description string
+	Source      string
+	Examples    []Example
+
+	StartLine       int
+	EndLine         int
+	TitleLine       int
+	DescriptionLine int
+	SourceLine      int
+}
+
+// Line is a single searchable line of a snippet
+type Line struct {
+	Number int
+	Field  Field
+	Text   string
+	// Example is the index into Snippet.Examples for code lines, -1 otherwise
+	Example int
+}
+
+// Document is a parsed llms.txt file
+type Document struct {
+	Metadata Metadata
+	Snippets []Snippet
+	// Lines are the raw lines of the file, Lines[0] being line 1
+	Lines []string
+}
+
+// ParseFile reads and parses the llms.txt file at path
+func ParseFile(path string) (*Document, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", path, err)
+	}
+	return Parse(content), nil
+}
+
+// Parse turns the content of an llms.txt file into a Document. Parsing is
+// lenient: malformed blocks are kept with whatever fields could be recognised.
+func Parse(content []byte) *Document {
+	lines := strings.Split(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+	doc := &Document{Lines: lines}
+	doc.Metadata = parseMetadata(lines)
+
+	p := parser{doc: doc}
+	for i := doc.Metadata.HeaderLines; i < len(lines); i++ {
+		p.feed(i+1, lines[i])
+	}
+	p.flush()
+
+	return doc
+}
+
+// parseMetadata reads the leading "#" comment lines of the file
+func parseMetadata(lines []string) Metadata {
+	meta := Metadata{Fields: make(map[string]string)}
+
+	for _, line := range lines {
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		meta.HeaderLines++
+
+		key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "#")), ":")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		meta.Fields[key] = value
+
+		switch key {
+		case "TOKEN_COUNT":
+			if count, err := strconv.Atoi(value); err == nil {
+				meta.TokenCount = count
+			}
+		case "DATE_CREATED":
+			meta.DateCreated = value
+		case "REPO":
+			meta.Repo = value
+		case "SOURCE":
+			meta.Source = value
+		}
+	}
+
+	return meta
+}
+
+// parser accumulates lines of the current block into a snippet
+type parser struct {
+	doc     *Document
+	current *Snippet
+	code    []string
+	inCode  bool
+	// inDescription is true while continuation lines belong to the description
+	inDescription bool
+	// awaitingCode is true between a CODE: marker and its opening fence
+	awaitingCode bool
+}
+
+func (p *parser) feed(number int, line string) {
+	if p.inCode {
+		if strings.HasPrefix(strings.TrimSpace(line), codeFence) {
+			p.inCode = false
+			p.current.EndLine = number
+			p.finishExample()
+			return
+		}
+		example := &p.current.Examples[len(p.current.Examples)-1]
+		if example.CodeLine == 0 {
+			example.CodeLine = number
+		}
+		p.code = append(p.code, line)
+		p.current.EndLine = number
+		return
+	}
+
+	trimmed := strings.TrimSpace(line)
+	if trimmed == Separator {
+		p.flush()
+		return
+	}
+	if trimmed == "" {
+		p.inDescription = false
+		return
+	}
+
+	if p.current == nil {
+		p.current = &Snippet{StartLine: number}
+	}
+	p.current.EndLine = number
+
+	switch {
+	case strings.HasPrefix(line, "TITLE:"):
+		p.inDescription = false
+		p.current.Title = strings.TrimSpace(strings.TrimPrefix(line, "TITLE:"))
+		p.current.TitleLine = number
+	case strings.HasPrefix(line, "DESCRIPTION:"):
+		p.current.Description = strings.TrimSpace(strings.TrimPrefix(line, "DESCRIPTION:"))
+		p.current.DescriptionLine = number
+		p.inDescription = true
+	case strings.HasPrefix(line, "SOURCE:"):
+		p.inDescription = false
+		p.current.Source = strings.TrimSpace(strings.TrimPrefix(line, "SOURCE:"))
+		p.current.SourceLine = number
+	case strings.HasPrefix(line, "LANGUAGE:"):
+		p.inDescription = false
+		p.current.Examples = append(p.current.Examples, Example{
+			Language:     strings.TrimSpace(strings.TrimPrefix(line, "LANGUAGE:")),
+			LanguageLine: number,
+		})
+	case strings.HasPrefix(line, "CODE:"):
+		p.inDescription = false
+		if len(p.current.Examples) == 0 {
+			p.current.Examples = append(p.current.Examples, Example{})
+		}
+		p.awaitingCode = true
+	case p.awaitingCode && strings.HasPrefix(trimmed, codeFence):
+		p.awaitingCode = false
+		p.inCode = true
+		p.code = nil
+	case p.inDescription && p.current.DescriptionLine+strings.Count(p.current.Description, "\n")+1 == number:
+		p.current.Description += "\n" + line
+	}
+}
+
+func (p *parser) finishExample() {
+	example := &p.current.Examples[len(p.current.Examples)-1]
+	example.Code = strings.Join(p.code, "\n")
+	p.code = nil
+}
+
+// flush stores the current snippet, if any, and resets the block state
+func (p *parser) flush() {
+	if p.inCode {
+		p.finishExample()
+	}
+	if p.current != nil && (p.current.Title != "" || p.current.Description != "" || len(p.current.Examples) > 0) {
+		if p.current.TitleLine != 0 {
+			p.current.StartLine = p.current.TitleLine
+		}
+		p.doc.Snippets = append(p.doc.Snippets, *p.current)
+	}
+	*p = parser{doc: p.doc}
+}
+
+// Validate checks that the document is in the snippet format: at least one
+// snippet, each with a TITLE
+func (d *Document) Validate() error {
+	if len(d.Snippets) == 0 {
+		return fmt.Errorf("no snippets found: expected TITLE, DESCRIPTION and CODE blocks separated by lines of %d dashes", len(Separator))
+	}
+	for _, snip := range d.Snippets {
+		if snip.Title == "" {
+			return fmt.Errorf("snippet at line %d has no TITLE", snip.StartLine)
+		}
+	}
+	return nil
+}
+
+// Line returns the raw text of a 1-based line number, or "" when out of range
+func (d *Document) Line(number int) string {
+	if number < 1 || number > len(d.Lines) {
+		return ""
+	}
+	return d.Lines[number-1]
+}
+
+// SnippetAt returns the snippet whose block contains the given line
+func (d *Document) SnippetAt(number int) (*Snippet, bool) {
+	for i := range d.Snippets {
+		if number >= d.Snippets[i].StartLine && number <= d.Snippets[i].EndLine {
+			return &d.Snippets[i], true
+		}
+	}
+	return nil, false
+}
+
+// Lines returns the title, description and code lines of the snippet in file order
+func (s *Snippet) Lines() []Line {
+	var lines []Line
+
+	if s.TitleLine != 0 {
+		lines = append(lines, Line{Number: s.TitleLine, Field: FieldTitle, Text: s.Title, Example: -1})
+	}
+	if s.DescriptionLine != 0 {
+		for i, text := range strings.Split(s.Description, "\n") {
+			lines = append(lines, Line{Number: s.DescriptionLine + i, Field: FieldDescription, Text: text, Example: -1})
+		}
+	}
+	for i, example := range s.Examples {
+		if example.CodeLine == 0 {
+			continue
+		}
+		for j, text := range strings.Split(example.Code, "\n") {
+			lines = append(lines, Line{Number: example.CodeLine + j, Field: FieldCode, Text: text, Example: i})
+		}
+	}
+
+	return lines
+}
+
+// Text returns the concatenated text of a single field of the snippet
+func (s *Snippet) Text(field Field) string {
+	switch field {
+	case FieldTitle:
+		return s.Title
+	case FieldDescription:
+		return s.Description
+	case FieldCode:
+		code := make([]string, 0, len(s.Examples))
+		for _, example := range s.Examples {
+			code = append(code, example.Code)
+		}
+		return strings.Join(code, "\n")
+	}
+	return ""
+}
+
+// languageAliases maps common alternative spellings to a canonical language name
+var languageAliases = map[string]string{
+	"golang":    "go",
+	"ts":        "typescript",
+	"tsx":       "typescript",
+	"js":        "javascript",
+	"jsx":       "javascript",
+	"py":        "python",
+	"sh":        "bash",
+	"shell":     "bash",
+	"zsh":       "bash",
+	"console":   "bash",
+	"yml":       "yaml",
+	"rs":        "rust",
+	"c++":       "cpp",
+	"c#":        "csharp",
+	"cs":        "csharp",
+	"md":        "markdown",
+	"plaintext": "text",
+	"txt":       "text",
+}
+
+// NormalizeLanguage lowercases a LANGUAGE value and resolves common aliases,
+// so that "Go", "go" and "golang" all compare equal
+func NormalizeLanguage(language string) string {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if canonical, ok := languageAliases[language]; ok {
+		return canonical
+	}
+	return language
+}
+
+// Languages returns the distinct LANGUAGE values of the snippet's examples
+func (s *Snippet) Languages() []string {
+	var languages []string
+	seen := make(map[string]bool)
+	for _, example := range s.Examples {
+		key := strings.ToLower(example.Language)
+		if example.Language == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		languages = append(languages, example.Language)
+	}
+	return languages
+}
@@ -0,0 +1,173 @@
+package snippet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const document = `# METADATA
+# TOKEN_COUNT: 1234
+# DATE_CREATED: 2025-06-26T10:30:45Z
+# REPO: mark3labs/mcp-go
+# SOURCE: https://context7.com/mark3labs/mcp-go/llms.txt
+#
+TITLE: Create a server
+DESCRIPTION: Creates an MCP server
+with tool capabilities.
+SOURCE: https://github.com/mark3labs/mcp-go/blob/main/README.md
+
+LANGUAGE: go
+CODE:
+` + "```" + `
+s := server.NewMCPServer("demo", "1.0.0")
+` + "```" + `
+
+LANGUAGE: bash
+CODE:
+` + "```" + `
+go get github.com/mark3labs/mcp-go
+` + "```" + `
+
+----------------------------------------
+
+TITLE: Empty code
+DESCRIPTION: An example without code
+SOURCE: s2
+
+LANGUAGE: text
+CODE:
+` + "```" + `
+` + "```" + `
+`
+
+func TestParse(t *testing.T) {
+	doc := Parse([]byte(document))
+
+	wantMeta := Metadata{
+		TokenCount:  1234,
+		DateCreated: "2025-06-26T10:30:45Z",
+		Repo:        "mark3labs/mcp-go",
+		Source:      "https://context7.com/mark3labs/mcp-go/llms.txt",
+		Fields: map[string]string{
+			"TOKEN_COUNT":  "1234",
+			"DATE_CREATED": "2025-06-26T10:30:45Z",
+			"REPO":         "mark3labs/mcp-go",
+			"SOURCE":       "https://context7.com/mark3labs/mcp-go/llms.txt",
+		},
+		HeaderLines: 6,
+	}
+	if !reflect.DeepEqual(doc.Metadata, wantMeta) {
+		t.Errorf("metadata = %+v, want %+v", doc.Metadata, wantMeta)
+	}
+
+	want := []Snippet{
+		{
+			Title:       "Create a server",
+			Description: "Creates an MCP server\nwith tool capabilities.",
+			Source:      "https://github.com/mark3labs/mcp-go/blob/main/README.md",
+			Examples: []Example{
+				{Language: "go", Code: `s := server.NewMCPServer("demo", "1.0.0")`, LanguageLine: 12, CodeLine: 15},
+				{Language: "bash", Code: "go get github.com/mark3labs/mcp-go", LanguageLine: 18, CodeLine: 21},
+			},
+			StartLine: 7, EndLine: 22, TitleLine: 7, DescriptionLine: 8, SourceLine: 10,
+		},
+		{
+			Title:       "Empty code",
+			Description: "An example without code",
+			Source:      "s2",
+			Examples:    []Example{{Language: "text", LanguageLine: 30}},
+			StartLine:   26, EndLine: 33, TitleLine: 26, DescriptionLine: 27, SourceLine: 28,
+		},
+	}
+	if !reflect.DeepEqual(doc.Snippets, want) {
+		t.Errorf("snippets = %+v\nwant %+v", doc.Snippets, want)
+	}
+	if err := doc.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	// Line numbers point at the stored lines
+	snip := &doc.Snippets[0]
+	for _, line := range snip.Lines() {
+		if raw := doc.Line(line.Number); !strings.Contains(raw, strings.SplitN(line.Text, "\n", 2)[0]) {
+			t.Errorf("line %d is %q, want it to hold %q", line.Number, raw, line.Text)
+		}
+	}
+	if got, ok := doc.SnippetAt(15); !ok || got.Title != "Create a server" {
+		t.Errorf("SnippetAt(15) = %v, %v, want Create a server", got, ok)
+	}
+	if _, ok := doc.SnippetAt(24); ok {
+		t.Error("SnippetAt of the separator found a snippet")
+	}
+	if langs := snip.Languages(); !reflect.DeepEqual(langs, []string{"go", "bash"}) {
+		t.Errorf("Languages() = %v, want go and bash", langs)
+	}
+}
+
+func TestParseLenient(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		titles  []string
+		valid   bool
+	}{
+		{"empty", "", nil, false},
+		{"header only", "# METADATA\n# REPO: a/b\n#\n", nil, false},
+		{"windows line endings", "TITLE: A\r\nDESCRIPTION: d\r\n", []string{"A"}, true},
+		{"no separator before end", "TITLE: A\nDESCRIPTION: d", []string{"A"}, true},
+		{"missing title", "DESCRIPTION: only a description\n", []string{""}, false},
+		{"blank blocks", "----------------------------------------\n\n----------------------------------------\nTITLE: B\n", []string{"B"}, true},
+		{"unterminated code", "TITLE: A\nLANGUAGE: go\nCODE:\n```\nfmt.Println()\n", []string{"A"}, true},
+		{"field marker in code", "TITLE: A\nCODE:\n```\nTITLE: not a title\n```\n", []string{"A"}, true},
+		{"plain markdown", "# Project\n\nSome prose.\n", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := Parse([]byte(tt.content))
+			var titles []string
+			for _, snip := range doc.Snippets {
+				titles = append(titles, snip.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) {
+				t.Errorf("titles = %q, want %q", titles, tt.titles)
+			}
+			if err := doc.Validate(); (err == nil) != tt.valid {
+				t.Errorf("Validate() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestRenderParseRoundTrip(t *testing.T) {
+	doc := Parse([]byte(document))
+	var snippets []*Snippet
+	for i := range doc.Snippets {
+		snippets = append(snippets, &doc.Snippets[i])
+	}
+	rendered := Render(snippets)
+
+	again := Parse(rendered)
+	if len(again.Snippets) != len(doc.Snippets) {
+		t.Fatalf("rendered document has %d snippets, want %d:\n%s", len(again.Snippets), len(doc.Snippets), rendered)
+	}
+	for i, snip := range again.Snippets {
+		original := doc.Snippets[i]
+		if snip.Title != original.Title || snip.Description != original.Description || snip.Source != original.Source {
+			t.Errorf("snippet %d = %q, %q, %q, want %q, %q, %q", i, snip.Title, snip.Description, snip.Source, original.Title, original.Description, original.Source)
+		}
+		if len(snip.Examples) != len(original.Examples) {
+			t.Errorf("snippet %d has %d examples, want %d", i, len(snip.Examples), len(original.Examples))
+			continue
+		}
+		for j, example := range snip.Examples {
+			if example.Language != original.Examples[j].Language || example.Code != original.Examples[j].Code {
+				t.Errorf("example %d of snippet %d = %+v, want %+v", j, i, example, original.Examples[j])
+			}
+		}
+	}
+
+	if twice := Render(snippets); string(twice) != string(rendered) {
+		t.Errorf("rendering is not deterministic")
+	}
+}
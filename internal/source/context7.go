@@ -0,0 +1,157 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"docs4context-com/internal/store"
+)
+
+// Context7 names the built-in context7.com source, whose base URL can be
+// changed to point at a mirror
+const Context7 = "context7"
+
+// DefaultTokenCount is requested when the size of a document is unknown
+const DefaultTokenCount = 100000000 // 100 million tokens
+
+// context7 fetches documents from context7.com, or from a server with the
+// same API: the document of a repository is served at /owner/repo/llms.txt
+// and its page at /owner/repo shows the token count
+type context7 struct {
+	name    string
+	baseURL string
+}
+
+// newContext7 creates a source for the context7 API at baseURL
+func newContext7(name, baseURL string) *context7 {
+	return &context7{name: name, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Name returns the name of the source
+func (c *context7) Name() string {
+	return c.name
+}
+
+// FetchMetadata retrieves the token count from the repository page
+func (c *context7) FetchMetadata(ctx context.Context, repo store.Repo) (Metadata, error) {
+	pageURL := fmt.Sprintf("%s/%s/%s", c.baseURL, repo.Owner, repo.Name)
+	log.Printf("Fetching token count from: %s", pageURL)
+
+	// Create a client with a User-Agent header to avoid being blocked
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to create request: %v", err)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/webp,*/*;q=0.8")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to fetch page: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("failed to fetch page, status: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	// Extract token count using multiple regex patterns
+	// First try: HTML structure with spans containing "Tokens:" followed by the number
+	// Pattern matches: <span>Tokens:</span><span>66,551</span>
+	tokenRegex := regexp.MustCompile(`<span[^>]*>Tokens:</span><span[^>]*>([0-9,]+)</span>`)
+	matches := tokenRegex.FindStringSubmatch(string(body))
+
+	if len(matches) < 2 {
+		// Second try: Look for "Tokens:" with optional whitespace and number in JSON or text
+		tokenRegex = regexp.MustCompile(`"?Tokens"?\s*:?\s*"?([0-9,]+)"?`)
+		matches = tokenRegex.FindStringSubmatch(string(body))
+	}
+
+	if len(matches) < 2 {
+		// Third try: More flexible pattern for Tokens followed by number
+		tokenRegex = regexp.MustCompile(`(?i)tokens[:\s]*([0-9,]+)`)
+		matches = tokenRegex.FindStringSubmatch(string(body))
+	}
+
+	if len(matches) < 2 {
+		// Final fallback: Look for any sequence that might contain token info
+		preview := string(body)
+		if len(preview) > 1000 {
+			preview = preview[:1000]
+		}
+		log.Printf("Debug: Page content preview: %s", preview)
+		log.Printf("Token count not found in page content, will use %d as fallback", DefaultTokenCount)
+		return Metadata{TokenCount: DefaultTokenCount}, nil
+	}
+
+	// Remove commas from the number and convert to int
+	tokenStr := strings.ReplaceAll(matches[1], ",", "")
+	tokenCount, err := strconv.Atoi(tokenStr)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("failed to parse token count: %v", err)
+	}
+
+	return Metadata{TokenCount: tokenCount}, nil
+}
+
+// FetchDocument downloads the llms.txt file, of opts.Version when it is set,
+// limited to opts.MaxTokens or else the default token count, and to
+// opts.Topic when it is set
+func (c *context7) FetchDocument(ctx context.Context, repo store.Repo, opts FetchOptions) (*Document, error) {
+	documentURL, err := c.documentURL(repo, opts)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Downloading context document from: %s", documentURL)
+
+	return download(ctx, documentURL, opts)
+}
+
+// documentURL returns the URL of the llms.txt file selected by opts
+func (c *context7) documentURL(repo store.Repo, opts FetchOptions) (string, error) {
+	tokenCount := opts.MaxTokens
+	if tokenCount <= 0 {
+		tokenCount = DefaultTokenCount
+	}
+	query := url.Values{"tokens": {strconv.Itoa(tokenCount)}}
+	if opts.Topic != "" {
+		query.Set("topic", opts.Topic)
+	}
+	path := repo.Owner + "/" + repo.Name
+	if opts.Version != "" {
+		if opts.Version == "." || opts.Version == ".." {
+			return "", fmt.Errorf("invalid version %q", opts.Version)
+		}
+		path += "/" + url.PathEscape(opts.Version)
+	}
+	return fmt.Sprintf("%s/%s/llms.txt?%s", c.baseURL, path, query.Encode()), nil
+}
+
+// DocumentURL returns the URL a server source downloads the document of repo
+// selected by opts from. ok is false for other sources.
+func DocumentURL(src Source, repo store.Repo, opts FetchOptions) (documentURL string, ok bool) {
+	c, ok := src.(*context7)
+	if !ok {
+		return "", false
+	}
+	documentURL, err := c.documentURL(repo, opts)
+	return documentURL, err == nil
+}
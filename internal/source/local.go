@@ -0,0 +1,54 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"docs4context-com/internal/store"
+)
+
+// localSource reads documents from a directory laid out like a store, with
+// the document of a repository at owner/repo/llms.txt
+type localSource struct {
+	name string
+	dir  string
+}
+
+// Name returns the name of the source
+func (l *localSource) Name() string {
+	return l.name
+}
+
+// FetchMetadata returns no metadata; the document is read whole
+func (l *localSource) FetchMetadata(ctx context.Context, repo store.Repo) (Metadata, error) {
+	return Metadata{}, nil
+}
+
+// FetchDocument reads the document of a repository from the directory
+func (l *localSource) FetchDocument(ctx context.Context, repo store.Repo, opts FetchOptions) (*Document, error) {
+	if err := checkWholeDocument(l.name, opts); err != nil {
+		return nil, err
+	}
+	path, err := filepath.Abs(repo.File(l.dir))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %v", repo.File(l.dir), err)
+	}
+	log.Printf("Reading context document from: %s", path)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %v", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("failed to read document: %s is not a regular file", path)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %v", err)
+	}
+	return &Document{Content: content, URL: path}, nil
+}
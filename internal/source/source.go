@@ -0,0 +1,169 @@
+package source
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"docs4context-com/internal/store"
+)
+
+// Default is the source used when a request does not name one
+const Default = Context7
+
+// Source types that can be defined in the config file
+const (
+	TypeServer = "server"
+	TypeLocal  = "local"
+	TypeURL    = "url"
+)
+
+// Source provides context documents for repositories
+type Source interface {
+	// Name is the name the source was selected by
+	Name() string
+	// FetchMetadata returns what the source knows about the document of a
+	// repository before it is downloaded
+	FetchMetadata(ctx context.Context, repo store.Repo) (Metadata, error)
+	// FetchDocument returns the document of a repository, or the part of it
+	// selected by opts
+	FetchDocument(ctx context.Context, repo store.Repo, opts FetchOptions) (*Document, error)
+}
+
+// FetchOptions select the part of a document to fetch
+type FetchOptions struct {
+	// Version selects the documentation of a library version
+	Version string
+	// Topic limits the document to snippets about a topic
+	Topic string
+	// MaxTokens limits the size of the document, or 0 to fetch all of it
+	MaxTokens int
+	// ETag and LastModified are the validators of a stored copy. When set,
+	// the download is conditional and fails with ErrNotModified if the
+	// document did not change.
+	ETag         string
+	LastModified string
+}
+
+// ErrNotModified is returned by FetchDocument when the server reports that
+// the document still matches the validators of FetchOptions
+var ErrNotModified = errors.New("document not modified")
+
+// Metadata describes a document held by a source
+type Metadata struct {
+	// TokenCount is the size of the document in tokens, or 0 when unknown
+	TokenCount int
+}
+
+// Document is a fetched context document
+type Document struct {
+	Content []byte
+	// URL is where the document was fetched from, recorded in its header
+	URL string
+	// ETag and LastModified are the validators the server sent, if any
+	ETag         string
+	LastModified string
+}
+
+// checkWholeDocument returns an error for options a source that can only
+// fetch whole documents does not support
+func checkWholeDocument(name string, opts FetchOptions) error {
+	if opts.Version != "" || opts.Topic != "" || opts.MaxTokens > 0 {
+		return fmt.Errorf("source %q only provides whole documents; 'version', 'topic' and 'max_tokens' are not supported", name)
+	}
+	return nil
+}
+
+// Get returns the source with the given name: one defined in the config
+// file or a built-in one. An empty name selects the default source.
+func Get(name string) (Source, error) {
+	if name == "" {
+		name = Default
+	}
+	if config, ok := store.Sources()[name]; ok {
+		return fromConfig(name, config)
+	}
+	if name == Context7 {
+		return newContext7(Context7, store.Context7URL()), nil
+	}
+	return nil, fmt.Errorf("unknown source %q: expected one of %s", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of the available sources, sorted
+func Names() []string {
+	names := []string{Context7}
+	for name := range store.Sources() {
+		if name != Context7 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fromConfig creates a source defined in the config file
+func fromConfig(name string, config store.SourceConfig) (Source, error) {
+	switch config.Type {
+	case TypeServer:
+		if config.URL == "" {
+			return nil, fmt.Errorf("source %q: a server source needs a 'url'", name)
+		}
+		return newContext7(name, config.URL), nil
+	case TypeLocal:
+		if config.Dir == "" {
+			return nil, fmt.Errorf("source %q: a local source needs a 'dir'", name)
+		}
+		return &localSource{name: name, dir: config.Dir}, nil
+	case TypeURL:
+		if config.URL == "" {
+			return nil, fmt.Errorf("source %q: a url source needs a 'url'", name)
+		}
+		return &urlSource{name: name, template: config.URL}, nil
+	default:
+		return nil, fmt.Errorf("source %q: invalid type %q: expected '%s', '%s' or '%s'", name, config.Type, TypeServer, TypeLocal, TypeURL)
+	}
+}
+
+// download fetches the document at url, conditionally when opts holds the
+// validators of a stored copy
+func download(ctx context.Context, url string, opts FetchOptions) (*Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	if opts.ETag != "" {
+		req.Header.Set("If-None-Match", opts.ETag)
+	}
+	if opts.LastModified != "" {
+		req.Header.Set("If-Modified-Since", opts.LastModified)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download document: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil, ErrNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download document, status: %d", resp.StatusCode)
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document content: %v", err)
+	}
+
+	return &Document{
+		Content:      content,
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, nil
+}
@@ -0,0 +1,43 @@
+package source
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"docs4context-com/internal/store"
+)
+
+// urlSource downloads documents from a URL template, in which {owner} and
+// {repo} are replaced by the parts of the repository identifier
+type urlSource struct {
+	name     string
+	template string
+}
+
+// Name returns the name of the source
+func (u *urlSource) Name() string {
+	return u.name
+}
+
+// ForURL returns a source that downloads the document at documentURL
+// whatever repository it is asked for
+func ForURL(documentURL string) Source {
+	return &urlSource{name: TypeURL, template: documentURL}
+}
+
+// FetchMetadata returns no metadata; the document is downloaded whole
+func (u *urlSource) FetchMetadata(ctx context.Context, repo store.Repo) (Metadata, error) {
+	return Metadata{}, nil
+}
+
+// FetchDocument downloads the document of a repository
+func (u *urlSource) FetchDocument(ctx context.Context, repo store.Repo, opts FetchOptions) (*Document, error) {
+	if err := checkWholeDocument(u.name, opts); err != nil {
+		return nil, err
+	}
+	url := strings.NewReplacer("{owner}", repo.Owner, "{repo}", repo.Name).Replace(u.template)
+	log.Printf("Downloading context document from: %s", url)
+
+	return download(ctx, url, opts)
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxNameLength bounds the owner and name of a repository identifier
+const maxNameLength = 100
+
+// DocumentFile is the name of the context document of a repository
+const DocumentFile = "llms.txt"
+
+// Repo is a validated "owner/name" repository identifier, optionally naming
+// a variant of the document as "owner/name@variant". All parts are single
+// path elements, so a Repo can be joined to a store root safely.
+type Repo struct {
+	Owner string
+	Name  string
+	// Variant names a partial document, e.g. one limited to a topic, stored
+	// alongside the full document; it is empty for the full document
+	Variant string
+}
+
+// ParseRepo validates an "owner/name" or "owner/name@variant" repository
+// identifier. Each part must start with a letter or digit and contain only
+// letters, digits, '-', '_' and '.', which rules out path separators and
+// "." or ".." elements.
+func ParseRepo(id string) (Repo, error) {
+	base, variant, hasVariant := strings.Cut(id, "@")
+	if hasVariant {
+		if err := validateName(variant); err != nil {
+			return Repo{}, fmt.Errorf("invalid repository %q: variant %v", id, err)
+		}
+	}
+	owner, name, ok := strings.Cut(base, "/")
+	if !ok || strings.Contains(name, "/") {
+		return Repo{}, fmt.Errorf("invalid repository %q: expected 'owner/name'", id)
+	}
+	if err := validateName(owner); err != nil {
+		return Repo{}, fmt.Errorf("invalid repository %q: owner %v", id, err)
+	}
+	if err := validateName(name); err != nil {
+		return Repo{}, fmt.Errorf("invalid repository %q: name %v", id, err)
+	}
+	return Repo{Owner: owner, Name: name, Variant: variant}, nil
+}
+
+// String returns the "owner/name" or "owner/name@variant" form of the identifier
+func (r Repo) String() string {
+	if r.Variant != "" {
+		return r.Owner + "/" + r.Name + "@" + r.Variant
+	}
+	return r.Owner + "/" + r.Name
+}
+
+// Dir returns the directory of the repository inside a store root
+func (r Repo) Dir(root string) string {
+	return filepath.Join(root, r.Owner, r.Name)
+}
+
+// File returns the context document of the repository inside a store root:
+// llms.txt, or llms.<variant>.txt for a variant
+func (r Repo) File(root string) string {
+	return filepath.Join(r.Dir(root), VariantFile(r.Variant))
+}
+
+// VariantFile returns the file name of a document variant
+func VariantFile(variant string) string {
+	if variant == "" {
+		return DocumentFile
+	}
+	return "llms." + variant + ".txt"
+}
+
+// RepoFromPath returns the repository of a document at relPath, relative to
+// a store root: "owner/name/llms.txt" or "owner/name/llms.<variant>.txt"
+func RepoFromPath(relPath string) (Repo, bool) {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	if len(parts) != 3 {
+		return Repo{}, false
+	}
+	id := parts[0] + "/" + parts[1]
+	if parts[2] != DocumentFile {
+		variant, ok := strings.CutPrefix(parts[2], "llms.")
+		variant, hasSuffix := strings.CutSuffix(variant, ".txt")
+		if !ok || !hasSuffix {
+			return Repo{}, false
+		}
+		id += "@" + variant
+	}
+	repo, err := ParseRepo(id)
+	return repo, err == nil
+}
+
+// VariantName returns the variant of a document limited to a topic and a
+// token count, e.g. "routing", "routing-tokens-5000" or "tokens-5000", with
+// characters a variant cannot hold replaced by '-'
+func VariantName(topic string, maxTokens int) string {
+	var parts []string
+	topic = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		}
+		return '-'
+	}, topic)
+	if topic = strings.Trim(topic, "-_"); topic != "" {
+		parts = append(parts, topic)
+	}
+	if maxTokens > 0 {
+		parts = append(parts, fmt.Sprintf("tokens-%d", maxTokens))
+	}
+
+	variant := strings.Join(parts, "-")
+	if len(variant) > maxNameLength {
+		variant = strings.TrimRight(variant[:maxNameLength], "-_")
+	}
+	return variant
+}
+
+// validateName checks one part of a repository identifier
+func validateName(part string) error {
+	if part == "" {
+		return fmt.Errorf("is empty")
+	}
+	if len(part) > maxNameLength {
+		return fmt.Errorf("is longer than %d characters", maxNameLength)
+	}
+	for i, r := range part {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case i == 0:
+			return fmt.Errorf("must start with a letter or digit, not %q", r)
+		case r == '-' || r == '_' || r == '.':
+		default:
+			return fmt.Errorf("contains %q; only letters, digits, '-', '_' and '.' are allowed", r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRepo(t *testing.T) {
+	valid := map[string]Repo{
+		"mark3labs/mcp-go":              {Owner: "mark3labs", Name: "mcp-go"},
+		"vercel/next.js":                {Owner: "vercel", Name: "next.js"},
+		"sst/opencode@routing":          {Owner: "sst", Name: "opencode", Variant: "routing"},
+		"a_b/c.d@tokens-5000":           {Owner: "a_b", Name: "c.d", Variant: "tokens-5000"},
+		"svelte.dev/llms-full":          {Owner: "svelte.dev", Name: "llms-full"},
+		"Owner/Repo@Topic_1.x":          {Owner: "Owner", Name: "Repo", Variant: "Topic_1.x"},
+		"0/0":                           {Owner: "0", Name: "0"},
+		strings.Repeat("a", 100) + "/b": {Owner: strings.Repeat("a", 100), Name: "b"},
+	}
+	for id, want := range valid {
+		got, err := ParseRepo(id)
+		if err != nil {
+			t.Errorf("ParseRepo(%q) returned error: %v", id, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("ParseRepo(%q) = %+v, want %+v", id, got, want)
+		}
+		if got.String() != id {
+			t.Errorf("ParseRepo(%q).String() = %q", id, got.String())
+		}
+	}
+
+	invalid := []string{
+		// Traversal
+		"../etc",
+		"../../etc/passwd",
+		"a/..",
+		"../b",
+		"./b",
+		"a/.",
+		"..%2f/b",
+		"a/b/../../etc",
+		"a/b/c",
+		"a/.hidden",
+		// Absolute paths
+		"/etc/passwd",
+		"/a/b",
+		`C:\Windows/b`,
+		// Variants
+		"a/b@../x",
+		"a/b@..",
+		"a/b@x/y",
+		"a/b@",
+		"a/b@.x",
+		"a/b@x@y",
+		"a/b@/etc",
+		// Separators and control characters
+		`a\b/c`,
+		`a/b\..\..\x`,
+		"a\x00/b",
+		"a/b\x00",
+		"a/b@x\x00",
+		"a /b",
+		"a/b\n",
+		// Malformed
+		"",
+		"a",
+		"/",
+		"a/",
+		"/b",
+		strings.Repeat("a", 101) + "/b",
+	}
+	for _, id := range invalid {
+		if repo, err := ParseRepo(id); err == nil {
+			t.Errorf("ParseRepo(%q) = %+v, want an error", id, repo)
+		}
+	}
+}
+
+func TestRepoFile(t *testing.T) {
+	root := filepath.Join("store", "root")
+	tests := map[string]string{
+		"a/b":         filepath.Join(root, "a", "b", "llms.txt"),
+		"a/b@routing": filepath.Join(root, "a", "b", "llms.routing.txt"),
+	}
+	for id, want := range tests {
+		repo, err := ParseRepo(id)
+		if err != nil {
+			t.Fatalf("ParseRepo(%q) returned error: %v", id, err)
+		}
+		if got := repo.File(root); got != want {
+			t.Errorf("File of %q = %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestRepoFromPath(t *testing.T) {
+	valid := map[string]string{
+		"a/b/llms.txt":         "a/b",
+		"a/b/llms.routing.txt": "a/b@routing",
+	}
+	for path, want := range valid {
+		repo, ok := RepoFromPath(path)
+		if !ok || repo.String() != want {
+			t.Errorf("RepoFromPath(%q) = %q, %v, want %q", path, repo, ok, want)
+		}
+	}
+
+	invalid := []string{
+		"../b/llms.txt",
+		"a/../llms.txt",
+		"a/b/c/llms.txt",
+		"a/b/other.txt",
+		"a/b/llms..txt",
+		"a/b/llms.../x.txt",
+		"llms.txt",
+	}
+	for _, path := range invalid {
+		if repo, ok := RepoFromPath(path); ok {
+			t.Errorf("RepoFromPath(%q) = %q, want no repository", path, repo)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CheckDir verifies that dir lies inside a trusted store root: the project
+// store, the global store or an extra root from the config file. Symbolic
+// links are resolved first, so a link inside a store cannot point outside it.
+func CheckDir(dir string) error {
+	mu.Lock()
+	trusted := trustedRoots()
+	mu.Unlock()
+
+	return checkWithin(dir, trusted)
+}
+
+// trustedRoots returns the roots configured by the user rather than by tool
+// calls. The caller must hold mu.
+func trustedRoots() []string {
+	trusted := append([]string{root}, extraRoots...)
+	if globalRoot != "" {
+		trusted = append(trusted, globalRoot)
+	}
+	return trusted
+}
+
+// checkWithin returns an error unless dir is one of roots or inside one
+func checkWithin(dir string, roots []string) error {
+	real, err := realPath(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", dir, err)
+	}
+	for _, root := range roots {
+		realRoot, err := realPath(root)
+		if err == nil && within(real, realRoot) {
+			return nil
+		}
+	}
+	return fmt.Errorf("path %q is outside the context stores (%s); set --store-root, --global-root or extra_roots in the config file to allow it", dir, strings.Join(roots, ", "))
+}
+
+// within reports whether path is base or inside it; both must be absolute and clean
+func within(path, base string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
+
+// realPath returns the absolute path with symbolic links resolved. Parts of
+// the path that do not exist yet are appended to the resolved existing part.
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
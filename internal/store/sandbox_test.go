@@ -0,0 +1,110 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// configureTemp points the project and global stores at new temporary
+// directories, with an empty config file, and returns their roots
+func configureTemp(t *testing.T) (project, global string) {
+	t.Helper()
+	dir := t.TempDir()
+	project = filepath.Join(dir, "project", "llm-context")
+	global = filepath.Join(dir, "global", "docs4context")
+	for _, root := range []string{project, global} {
+		if err := os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Configure(Options{Root: project, GlobalRoot: global, ConfigPath: configPath}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		Configure(Options{ConfigPath: configPath})
+	})
+	return project, global
+}
+
+func TestCheckDir(t *testing.T) {
+	project, global := configureTemp(t)
+	outside := t.TempDir()
+
+	allowed := []string{
+		project,
+		global,
+		filepath.Join(project, "a", "b"),
+		filepath.Join(global, "a", "b"),
+		filepath.Join(project, "a", "..", "b"),
+		filepath.Join(project, "not", "created", "yet"),
+	}
+	for _, dir := range allowed {
+		if err := CheckDir(dir); err != nil {
+			t.Errorf("CheckDir(%q) returned error: %v", dir, err)
+		}
+	}
+
+	rejected := []string{
+		outside,
+		filepath.Dir(project),
+		filepath.Join(project, ".."),
+		filepath.Join(project, "..", "..", "etc"),
+		filepath.Join(project, "a", "..", "..", "x"),
+		project + "-sibling",
+		"/",
+		"/etc",
+	}
+	for _, dir := range rejected {
+		if err := CheckDir(dir); err == nil {
+			t.Errorf("CheckDir(%q) succeeded, want an error", dir)
+		}
+	}
+}
+
+func TestCheckDirSymlinks(t *testing.T) {
+	project, global := configureTemp(t)
+	outside := t.TempDir()
+
+	// An owner directory and a repository directory linking outside the store
+	if err := os.Symlink(outside, filepath.Join(project, "evil")); err != nil {
+		t.Skipf("symbolic links unavailable: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(global, "owner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(global, "owner", "repo")); err != nil {
+		t.Fatal(err)
+	}
+	// A link to another place inside the stores is fine
+	if err := os.Symlink(global, filepath.Join(project, "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := []string{
+		filepath.Join(project, "evil"),
+		filepath.Join(project, "evil", "repo"),
+		filepath.Join(project, "evil", "repo", "not", "created"),
+		filepath.Join(global, "owner", "repo"),
+		filepath.Join(global, "owner", "repo", "sub"),
+	}
+	for _, dir := range rejected {
+		if err := CheckDir(dir); err == nil {
+			t.Errorf("CheckDir(%q) succeeded, want an error", dir)
+		}
+	}
+
+	allowed := []string{
+		filepath.Join(project, "shared"),
+		filepath.Join(project, "shared", "owner"),
+	}
+	for _, dir := range allowed {
+		if err := CheckDir(dir); err != nil {
+			t.Errorf("CheckDir(%q) returned error: %v", dir, err)
+		}
+	}
+}
@@ -0,0 +1,438 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// DefaultRoot is the project store root used when none is configured
+const DefaultRoot = "llm-context"
+
+// Environment variables that configure the store
+const (
+	EnvRoot        = "DOCS4CONTEXT_STORE_ROOT"
+	EnvGlobalRoot  = "DOCS4CONTEXT_GLOBAL_ROOT"
+	EnvConfig      = "DOCS4CONTEXT_CONFIG"
+	EnvContext7URL = "DOCS4CONTEXT_CONTEXT7_URL"
+)
+
+// DefaultContext7URL is the base URL of context7.com
+const DefaultContext7URL = "https://context7.com"
+
+// Scopes name the stores documents can be saved to. The project store is
+// the store root; the global store is shared by every project of the user.
+const (
+	ScopeProject = "project"
+	ScopeGlobal  = "global"
+)
+
+// DefaultScope is the store documents are saved to when no scope is given:
+// the project store, so projects that commit their store keep working
+const DefaultScope = ScopeProject
+
+// DefaultSnapshotRetention is the number of snapshots kept of each document
+// when the config file does not set snapshot_retention
+const DefaultSnapshotRetention = 5
+
+// SnapshotDir is the directory inside a store root holding the earlier
+// versions of its documents
+const SnapshotDir = ".snapshots"
+
+// registryFile lists, inside the store root, the other directories that
+// save_context_document wrote documents to
+const registryFile = ".roots.json"
+
+// Config is the content of the config file
+type Config struct {
+	// StoreRoot is the project store; a relative path is resolved against
+	// the directory of the config file
+	StoreRoot string `json:"store_root"`
+	// GlobalRoot is the user-level store shared by all projects
+	GlobalRoot string `json:"global_root"`
+	// ExtraRoots are further directories searched for documents
+	ExtraRoots []string `json:"extra_roots"`
+	// Context7URL is the base URL of the context7 source, e.g. a mirror
+	Context7URL string `json:"context7_url"`
+	// Sources defines document sources by name, in addition to the built-in ones
+	Sources map[string]SourceConfig `json:"sources"`
+	// SnapshotRetention is the number of earlier versions kept of each
+	// document when it is replaced; 0 disables snapshots
+	SnapshotRetention *int `json:"snapshot_retention"`
+}
+
+// SourceConfig defines a document source in the config file
+type SourceConfig struct {
+	// Type is the kind of source: "server", "local" or "url"
+	Type string `json:"type"`
+	// URL is the base URL of a server, or the URL template of a url source
+	URL string `json:"url,omitempty"`
+	// Dir is the directory of a local source; a relative path is resolved
+	// against the directory of the config file
+	Dir string `json:"dir,omitempty"`
+}
+
+// Options are the command line settings for the store
+type Options struct {
+	Root       string
+	GlobalRoot string
+	ConfigPath string
+	// Context7URL is the base URL of the context7 source
+	Context7URL string
+}
+
+var (
+	mu          sync.Mutex
+	root        = DefaultRoot
+	globalRoot  = DefaultGlobalRoot()
+	extraRoots  []string
+	sources     map[string]SourceConfig
+	context7URL = DefaultContext7URL
+	retention   = DefaultSnapshotRetention
+)
+
+// Configure sets the project and global store roots from, in order of
+// precedence, the command line, the DOCS4CONTEXT_STORE_ROOT and
+// DOCS4CONTEXT_GLOBAL_ROOT environment variables, the config file and the
+// defaults, and likewise the context7 base URL. opts.ConfigPath overrides
+// the config file location; when it is empty DOCS4CONTEXT_CONFIG or the user
+// config directory is used, and a missing default config file is not an
+// error. The snapshot retention is only read from the config file.
+func Configure(opts Options) error {
+	configPath := opts.ConfigPath
+	explicit := configPath != ""
+	if !explicit {
+		configPath = os.Getenv(EnvConfig)
+		explicit = configPath != ""
+	}
+	if !explicit {
+		configPath = DefaultConfigPath()
+	}
+
+	var config Config
+	if configPath != "" {
+		loaded, err := readConfig(configPath)
+		switch {
+		case err == nil:
+			config = loaded
+		case explicit || !os.IsNotExist(err):
+			return fmt.Errorf("failed to read config file %s: %v", configPath, err)
+		}
+	}
+
+	baseURL := DefaultContext7URL
+	if config.Context7URL != "" {
+		baseURL = config.Context7URL
+	}
+	if envURL := os.Getenv(EnvContext7URL); envURL != "" {
+		baseURL = envURL
+	}
+	if opts.Context7URL != "" {
+		baseURL = opts.Context7URL
+	}
+	if err := checkBaseURL(baseURL); err != nil {
+		return fmt.Errorf("invalid context7 URL: %v", err)
+	}
+	if config.SnapshotRetention != nil && *config.SnapshotRetention < 0 {
+		return fmt.Errorf("invalid config file %s: snapshot_retention must not be negative", configPath)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	context7URL = strings.TrimSuffix(baseURL, "/")
+	root = DefaultRoot
+	if config.StoreRoot != "" {
+		root = resolve(config.StoreRoot, filepath.Dir(configPath))
+	}
+	if envRoot := os.Getenv(EnvRoot); envRoot != "" {
+		root = envRoot
+	}
+	if opts.Root != "" {
+		root = opts.Root
+	}
+
+	globalRoot = DefaultGlobalRoot()
+	if config.GlobalRoot != "" {
+		globalRoot = resolve(config.GlobalRoot, filepath.Dir(configPath))
+	}
+	if envGlobalRoot := os.Getenv(EnvGlobalRoot); envGlobalRoot != "" {
+		globalRoot = envGlobalRoot
+	}
+	if opts.GlobalRoot != "" {
+		globalRoot = opts.GlobalRoot
+	}
+
+	extraRoots = nil
+	for _, dir := range config.ExtraRoots {
+		extraRoots = append(extraRoots, resolve(dir, filepath.Dir(configPath)))
+	}
+
+	retention = DefaultSnapshotRetention
+	if config.SnapshotRetention != nil {
+		retention = *config.SnapshotRetention
+	}
+
+	sources = make(map[string]SourceConfig, len(config.Sources))
+	for name, source := range config.Sources {
+		if source.Dir != "" {
+			source.Dir = resolve(source.Dir, filepath.Dir(configPath))
+		}
+		sources[name] = source
+	}
+
+	return nil
+}
+
+// DefaultConfigPath returns the config file location in the user config
+// directory, or "" when that directory is unknown
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "docs4context", "config.json")
+}
+
+// DefaultGlobalRoot returns the global store in the user data directory,
+// or "" when the home directory is unknown
+func DefaultGlobalRoot() string {
+	dir, err := dataDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "docs4context")
+}
+
+// dataDir returns the user data directory: $XDG_DATA_HOME or ~/.local/share
+// on Unix, the application support directory on macOS and %LocalAppData% on
+// Windows
+func dataDir() (string, error) {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return dir, nil
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		return os.UserConfigDir()
+	case "windows":
+		if dir := os.Getenv("LocalAppData"); dir != "" {
+			return dir, nil
+		}
+		return os.UserConfigDir()
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share"), nil
+}
+
+// Root returns the project store root
+func Root() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return root
+}
+
+// GlobalRoot returns the global store root, or "" when it is unknown
+func GlobalRoot() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return globalRoot
+}
+
+// Context7URL returns the base URL of the context7 source
+func Context7URL() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return context7URL
+}
+
+// SnapshotRetention returns the number of snapshots kept of each document
+func SnapshotRetention() int {
+	mu.Lock()
+	defer mu.Unlock()
+	return retention
+}
+
+// Sources returns the document sources defined in the config file
+func Sources() map[string]SourceConfig {
+	mu.Lock()
+	defer mu.Unlock()
+	defined := make(map[string]SourceConfig, len(sources))
+	for name, source := range sources {
+		defined[name] = source
+	}
+	return defined
+}
+
+// RootFor returns the root of the store for a scope
+func RootFor(scope string) (string, error) {
+	switch scope {
+	case ScopeProject:
+		return Root(), nil
+	case ScopeGlobal:
+		if dir := GlobalRoot(); dir != "" {
+			return dir, nil
+		}
+		return "", fmt.Errorf("the global store is unavailable: set %s or global_root in the config file", EnvGlobalRoot)
+	default:
+		return "", fmt.Errorf("invalid scope %q: expected '%s' or '%s'", scope, ScopeProject, ScopeGlobal)
+	}
+}
+
+// Scope names the store a root directory belongs to: "project", "global",
+// or "extra" for other directories
+func Scope(dir string) string {
+	mu.Lock()
+	defer mu.Unlock()
+	switch {
+	case sameDir(dir, root):
+		return ScopeProject
+	case globalRoot != "" && sameDir(dir, globalRoot):
+		return ScopeGlobal
+	}
+	return "extra"
+}
+
+// Roots returns the existing directories to search for documents, in order
+// of precedence: the project store, the directories from the config file,
+// those recorded by Register, and the global store last so that project
+// documents shadow global ones. Duplicates and recorded directories outside
+// the trusted roots are removed.
+func Roots() []string {
+	mu.Lock()
+	candidates := append([]string{root}, extraRoots...)
+	registryPath := filepath.Join(root, registryFile)
+	global := globalRoot
+	trusted := trustedRoots()
+	mu.Unlock()
+
+	registered, _ := readRegistry(registryPath)
+	for _, dir := range registered {
+		if checkWithin(dir, trusted) == nil {
+			candidates = append(candidates, dir)
+		}
+	}
+	if global != "" {
+		candidates = append(candidates, global)
+	}
+
+	var roots []string
+	seen := make(map[string]bool)
+	for _, dir := range candidates {
+		abs, err := filepath.Abs(dir)
+		if err != nil || seen[abs] {
+			continue
+		}
+		seen[abs] = true
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			roots = append(roots, dir)
+		}
+	}
+	return roots
+}
+
+// Register records a directory inside the trusted roots that documents were
+// saved to, so that searches find them. Registering a root is a no-op.
+func Register(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", dir, err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	trusted := trustedRoots()
+	if err := checkWithin(abs, trusted); err != nil {
+		return err
+	}
+	for _, trustedRoot := range trusted {
+		if sameDir(abs, trustedRoot) {
+			return nil
+		}
+	}
+
+	registryPath := filepath.Join(root, registryFile)
+	registered, err := readRegistry(registryPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, existing := range registered {
+		if existing == abs {
+			return nil
+		}
+	}
+	registered = append(registered, abs)
+
+	data, err := json.MarshalIndent(registered, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %v", registryPath, err)
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", root, err)
+	}
+	if err := os.WriteFile(registryPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %v", registryPath, err)
+	}
+	return nil
+}
+
+// readConfig decodes the config file at path
+func readConfig(path string) (Config, error) {
+	var config Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid JSON: %v", err)
+	}
+	return config, nil
+}
+
+// readRegistry returns the directories recorded by Register
+func readRegistry(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	if err := json.Unmarshal(data, &dirs); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %v", path, err)
+	}
+	return dirs, nil
+}
+
+// sameDir reports whether two paths name the same directory
+func sameDir(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// checkBaseURL verifies that url is an absolute http or https URL
+func checkBaseURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", rawURL)
+	}
+	return nil
+}
+
+// resolve makes a relative path from the config file relative to its directory
+func resolve(path, base string) string {
+	if filepath.IsAbs(path) || base == "" {
+		return path
+	}
+	return filepath.Join(base, path)
+}
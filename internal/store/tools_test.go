@@ -0,0 +1,212 @@
+package store_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"docs4context-com/internal/savecontext"
+	"docs4context-com/internal/search"
+	"docs4context-com/internal/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const document = `TITLE: Secret
+DESCRIPTION: A document outside the stores
+SOURCE: https://example.com/secret
+LANGUAGE: text
+CODE:
+` + "```\nsecret\n```\n"
+
+// newStores configures temporary project and global stores and a directory
+// outside them holding a document, and returns a server with the tools
+func newStores(t *testing.T) (s *server.MCPServer, project, global, outside string) {
+	t.Helper()
+	dir := t.TempDir()
+	project = filepath.Join(dir, "project", "llm-context")
+	global = filepath.Join(dir, "global", "docs4context")
+	outside = filepath.Join(dir, "outside")
+	for _, root := range []string{project, global, filepath.Join(outside, "repo")} {
+		if err := os.MkdirAll(root, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(outside, "repo", store.DocumentFile), []byte(document), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Configure(store.Options{Root: project, GlobalRoot: global, ConfigPath: configPath}); err != nil {
+		t.Fatal(err)
+	}
+
+	s = server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	savecontext.AddTool(s)
+	search.AddGetTopicDetails(s)
+	return s, project, global, outside
+}
+
+// callTool calls a tool and returns its text and whether it reported an error
+func callTool(t *testing.T, s *server.MCPServer, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, ok := s.HandleMessage(context.Background(), message).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("%s returned no result", name)
+	}
+	result, ok := response.Result.(mcp.CallToolResult)
+	if !ok {
+		t.Fatalf("%s returned %T", name, response.Result)
+	}
+	var text []string
+	for _, content := range result.Content {
+		if textContent, ok := content.(mcp.TextContent); ok {
+			text = append(text, textContent.Text)
+		}
+	}
+	return strings.Join(text, "\n"), result.IsError
+}
+
+// serveDocument serves the document and returns its URL
+func serveDocument(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, document)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/llms.txt"
+}
+
+// assertUntouched fails when a file other than the original document was
+// written outside the stores
+func assertUntouched(t *testing.T, outside string) {
+	t.Helper()
+	var files []string
+	filepath.WalkDir(outside, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if len(files) != 1 || files[0] != filepath.Join(outside, "repo", store.DocumentFile) {
+		t.Errorf("files outside the stores = %v, want only the original document", files)
+	}
+	content, err := os.ReadFile(filepath.Join(outside, "repo", store.DocumentFile))
+	if err != nil || string(content) != document {
+		t.Errorf("document outside the stores was changed: %v", err)
+	}
+}
+
+func TestSaveContextDocumentRejectsEscapes(t *testing.T) {
+	s, project, global, outside := newStores(t)
+	documentURL := serveDocument(t)
+
+	// Symbolic links from the stores to the outside directory
+	if err := os.Symlink(outside, filepath.Join(project, "evil")); err != nil {
+		t.Skipf("symbolic links unavailable: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(global, "owner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "repo"), filepath.Join(global, "owner", "repo")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]map[string]any{
+		"traversal in github_url":      {"github_url": "../etc"},
+		"traversal in repo":            {"github_url": documentURL, "repo": "../etc"},
+		"traversal in repo name":       {"github_url": documentURL, "repo": "a/.."},
+		"traversal in variant":         {"github_url": documentURL, "repo": "a/b@../x"},
+		"absolute repo":                {"github_url": documentURL, "repo": "/etc/passwd"},
+		"backslash in repo":            {"github_url": documentURL, "repo": `a\b/c`},
+		"NUL in repo":                  {"github_url": documentURL, "repo": "a\x00/b"},
+		"output_dir outside":           {"github_url": documentURL, "repo": "a/b", "output_dir": outside},
+		"output_dir traversal":         {"github_url": documentURL, "repo": "a/b", "output_dir": filepath.Join(project, "..", "..", "outside")},
+		"output_dir above the stores":  {"github_url": documentURL, "repo": "a/b", "output_dir": filepath.Dir(outside)},
+		"symlinked owner directory":    {"github_url": documentURL, "repo": "evil/repo", "scope": store.ScopeProject},
+		"symlinked repository":         {"github_url": documentURL, "repo": "owner/repo", "scope": store.ScopeGlobal},
+		"symlinked output_dir":         {"github_url": documentURL, "repo": "a/b", "output_dir": filepath.Join(project, "evil")},
+		"symlinked variant repository": {"github_url": documentURL, "repo": "owner/repo@x", "scope": store.ScopeGlobal},
+	}
+	for name, args := range tests {
+		t.Run(name, func(t *testing.T) {
+			text, isError := callTool(t, s, "save_context_document", args)
+			if !isError {
+				t.Errorf("save_context_document(%v) succeeded: %s", args, text)
+			}
+		})
+	}
+	assertUntouched(t, outside)
+
+	// The same document inside the store is saved
+	text, isError := callTool(t, s, "save_context_document", map[string]any{"github_url": documentURL, "repo": "a/b", "scope": store.ScopeProject})
+	if isError {
+		t.Fatalf("save_context_document into the project store failed: %s", text)
+	}
+	if _, err := os.Stat(filepath.Join(project, "a", "b", store.DocumentFile)); err != nil {
+		t.Errorf("document was not saved: %v", err)
+	}
+}
+
+func TestGetTopicDetailsRejectsEscapes(t *testing.T) {
+	s, project, global, outside := newStores(t)
+
+	if err := os.Symlink(outside, filepath.Join(project, "evil")); err != nil {
+		t.Skipf("symbolic links unavailable: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(global, "owner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "repo"), filepath.Join(global, "owner", "repo")); err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := []string{
+		"../outside",
+		"../../etc",
+		"a/..",
+		"../outside/repo",
+		"a/b@../x",
+		"a/b@../../outside/repo/llms",
+		"/etc/passwd",
+		filepath.Join(outside, "repo"),
+		`a\b/c`,
+		"a\x00/b",
+	}
+	for _, repo := range rejected {
+		text, isError := callTool(t, s, "get_topic_details", map[string]any{"repo": repo, "line_numbers": "1"})
+		if !isError {
+			t.Errorf("get_topic_details(%q) succeeded: %s", repo, text)
+		}
+	}
+
+	// Documents reached through symbolic links leading outside the stores
+	// are not read
+	for _, repo := range []string{"evil/repo", "owner/repo"} {
+		text, isError := callTool(t, s, "get_topic_details", map[string]any{"repo": repo, "line_numbers": "1"})
+		if strings.Contains(text, "Secret") {
+			t.Errorf("get_topic_details(%q) read the document outside the stores: %s", repo, text)
+		}
+		if !isError && !strings.Contains(text, "not found") {
+			t.Errorf("get_topic_details(%q) = %s, want an error or not found", repo, text)
+		}
+	}
+}
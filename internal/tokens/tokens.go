@@ -0,0 +1,49 @@
+package tokens
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// encodingName is the cl100k_base encoding used by GPT-4 and GPT-3.5-turbo
+const encodingName = "cl100k_base"
+
+var (
+	encodingMu sync.Mutex
+	encoding   *tiktoken.Tiktoken
+)
+
+// Count counts the number of tokens in text using tiktoken. The encoding is
+// loaded on first use and reused afterwards; a failed load is retried on the
+// next call.
+func Count(text string) (int, error) {
+	encodingMu.Lock()
+	if encoding == nil {
+		loaded, err := tiktoken.GetEncoding(encodingName)
+		if err != nil {
+			encodingMu.Unlock()
+			return 0, fmt.Errorf("failed to get encoding: %v", err)
+		}
+		encoding = loaded
+	}
+	enc := encoding
+	encodingMu.Unlock()
+
+	return len(enc.Encode(text, nil, nil)), nil
+}
+
+// Estimate approximates the token count of text at four bytes per token, for
+// use when the encoding cannot be loaded
+func Estimate(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// CountOrEstimate counts tokens with Count, falling back to Estimate
+func CountOrEstimate(text string) int {
+	if count, err := Count(text); err == nil {
+		return count
+	}
+	return Estimate(text)
+}
@@ -7,8 +7,13 @@ import (
 	"os"
 	"runtime"
 
+	"docs4context-com/internal/diff"
+	"docs4context-com/internal/discover"
+	"docs4context-com/internal/manifest"
 	"docs4context-com/internal/savecontext"
 	"docs4context-com/internal/search"
+	"docs4context-com/internal/snapshot"
+	"docs4context-com/internal/store"
 	"docs4context-com/internal/updater"
 
 	"github.com/mark3labs/mcp-go/server"
@@ -24,10 +29,14 @@ var (
 func main() {
 	// Command line flags
 	var (
-		showVersion   = flag.Bool("version", false, "Show version information")
-		showHelp      = flag.Bool("help", false, "Show help information")
-		updateBinary  = flag.Bool("update", false, "Check for and install updates")
-		checkUpdates  = flag.Bool("check-updates", false, "Check for available updates without installing")
+		showVersion  = flag.Bool("version", false, "Show version information")
+		showHelp     = flag.Bool("help", false, "Show help information")
+		updateBinary = flag.Bool("update", false, "Check for and install updates")
+		checkUpdates = flag.Bool("check-updates", false, "Check for available updates without installing")
+		storeRoot    = flag.String("store-root", "", "Project store directory for context documents")
+		globalRoot   = flag.String("global-root", "", "Global store directory shared by all projects")
+		configPath   = flag.String("config", "", "Path to the config file")
+		context7URL  = flag.String("context7-url", "", "Base URL of context7.com or a mirror")
 	)
 	flag.Parse()
 
@@ -46,12 +55,38 @@ func main() {
 		fmt.Println("docs4context MCP Server")
 		fmt.Println("Usage:")
 		fmt.Println("  docs4context-com [options]")
+		fmt.Println("  docs4context-com [options] <command> [arguments]")
 		fmt.Println("")
 		fmt.Println("Options:")
 		fmt.Println("  --version         Show version information")
 		fmt.Println("  --help            Show this help message")
 		fmt.Println("  --update          Check for and install updates")
 		fmt.Println("  --check-updates   Check for available updates without installing")
+		fmt.Println("  --store-root DIR  Project store directory for context documents")
+		fmt.Println("                    (default: $" + store.EnvRoot + ", the config file or 'llm-context')")
+		fmt.Println("  --global-root DIR Global store directory shared by all projects")
+		fmt.Println("                    (default: $" + store.EnvGlobalRoot + ", the config file or " + store.DefaultGlobalRoot() + ")")
+		fmt.Println("  --config FILE     Path to the config file")
+		fmt.Println("                    (default: $" + store.EnvConfig + " or " + store.DefaultConfigPath() + ")")
+		fmt.Println("  --context7-url URL Base URL of context7.com or a mirror")
+		fmt.Println("                    (default: $" + store.EnvContext7URL + ", the config file or " + store.DefaultContext7URL + ")")
+		fmt.Println("")
+		fmt.Println("Commands:")
+		fmt.Println("  import [--repo owner/name] [--scope project|global] [--output-dir DIR] PATH")
+		fmt.Println("                    Import a local llms.txt file, or a directory of")
+		fmt.Println("                    owner/repo/llms.txt files, into the store")
+		fmt.Println("  batch [--manifest FILE] [--concurrency N] [--scope project|global]")
+		fmt.Println("        [--output-dir DIR] [--source NAME] [REPO...]")
+		fmt.Println("                    Download many repositories concurrently")
+		fmt.Println("  sync [--manifest FILE] [--dry-run] [--concurrency N]")
+		fmt.Println("                    Sync the project store with docs4context.json")
+		fmt.Println("  discover [--run] [--concurrency N] [--scope project|global]")
+		fmt.Println("           [--output-dir DIR] [DIR]")
+		fmt.Println("                    Propose, or download with --run, the documents of the")
+		fmt.Println("                    dependencies in go.mod, package.json and similar files")
+		fmt.Println("  refresh [--max-age-days N] [--dry-run] [--concurrency N]")
+		fmt.Println("          [--scope project|global] [--output-dir DIR] [REPO...]")
+		fmt.Println("                    Download stale documents again, rewriting changed ones")
 		fmt.Println("")
 		fmt.Println("This is an MCP (Model Context Protocol) server that provides")
 		fmt.Println("document context and search tools for AI agents.")
@@ -80,7 +115,7 @@ func main() {
 			fmt.Printf("Error checking for updates: %v\n", err)
 			os.Exit(1)
 		}
-		
+
 		if hasUpdate {
 			fmt.Printf("New version available: %s\n", release.TagName)
 			fmt.Printf("Current version: %s\n", Version)
@@ -99,18 +134,18 @@ func main() {
 			fmt.Printf("Error checking for updates: %v\n", err)
 			os.Exit(1)
 		}
-		
+
 		if !hasUpdate {
 			fmt.Println("You are already running the latest version")
 			return
 		}
-		
+
 		fmt.Printf("Updating from %s to %s...\n", Version, release.TagName)
 		if err := updater.DownloadUpdate(release); err != nil {
 			fmt.Printf("Error updating: %v\n", err)
 			os.Exit(1)
 		}
-		
+
 		fmt.Println("Update completed successfully!")
 		fmt.Println("Please restart the application to use the new version")
 		fmt.Println("Run with --help to see how to configure your AI agent")
@@ -122,7 +157,22 @@ func main() {
 	log.SetPrefix("[docs4context] ")
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
 
+	// Locate the context document store
+	if err := store.Configure(store.Options{Root: *storeRoot, GlobalRoot: *globalRoot, ConfigPath: *configPath, Context7URL: *context7URL}); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+
+	// Run a command instead of the server when one is given
+	if flag.NArg() > 0 {
+		if err := runCommand(flag.Args()); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	log.Printf("Starting docs4context MCP Server %s", Version)
+	log.Printf("Using project store %s, global store %s and context7 at %s", store.Root(), store.GlobalRoot(), store.Context7URL())
 
 	// Create a new MCP server
 	s := server.NewMCPServer(
@@ -137,6 +187,42 @@ func main() {
 	savecontext.AddTool(s)
 	log.Println("Tool registered successfully")
 
+	// Add the local document import tool
+	log.Println("Registering import_context_document tool...")
+	savecontext.AddImportTool(s)
+	log.Println("Tool registered successfully")
+
+	// Add the batch download tool
+	log.Println("Registering batch_save_context_documents tool...")
+	savecontext.AddBatchTool(s)
+	log.Println("Tool registered successfully")
+
+	// Add the stale document refresh tool
+	log.Println("Registering refresh_context_documents tool...")
+	savecontext.AddRefreshTool(s)
+	log.Println("Tool registered successfully")
+
+	// Add the project manifest sync tool
+	log.Println("Registering sync_context_documents tool...")
+	manifest.AddSyncTool(s)
+	log.Println("Tool registered successfully")
+
+	// Add the dependency discovery tool
+	log.Println("Registering discover_dependencies tool...")
+	discover.AddDiscoverTool(s)
+	log.Println("Tool registered successfully")
+
+	// Add the snapshot tools
+	log.Println("Registering snapshot tools...")
+	snapshot.AddListTool(s)
+	snapshot.AddRestoreTool(s)
+	log.Println("Snapshot tools registered successfully")
+
+	// Add the document diff tool
+	log.Println("Registering diff_context tool...")
+	diff.AddDiffTool(s)
+	log.Println("Tool registered successfully")
+
 	// Add search tools
 	log.Println("Registering search tools...")
 	search.AddSearchTitles(s)